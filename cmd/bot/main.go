@@ -12,6 +12,17 @@ import (
 	"trinity_bot/internal/config"
 	"trinity_bot/internal/db"
 	"trinity_bot/internal/storage"
+
+	// Platform connectors register their publishers in init
+	_ "trinity_bot/internal/connectors/bluesky"
+	_ "trinity_bot/internal/connectors/facebook"
+	_ "trinity_bot/internal/connectors/instagram"
+	_ "trinity_bot/internal/connectors/linkedin"
+	_ "trinity_bot/internal/connectors/mastodon"
+	_ "trinity_bot/internal/connectors/pinterest"
+	_ "trinity_bot/internal/connectors/threads"
+	_ "trinity_bot/internal/connectors/tiktok"
+	_ "trinity_bot/internal/connectors/twitter"
 )
 
 func main() {
@@ -46,12 +57,39 @@ func main() {
 	repo := storage.New(sqlDB)
 
 	// Initialize bot
-	telegramBot, err := bot.New(cfg, repo)
+	accounts := storage.NewAccounts(sqlDB, cfg.SecretKeys)
+	if cfg.SecretKeys == nil {
+		slog.Warn("No master key configured; accounts with credentials cannot be stored")
+	}
+
+	// "reencrypt" seals all stored credentials with the active master key and exits
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		rctx, rcancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		n, err := accounts.ReencryptAccounts(rctx)
+		rcancel()
+		if err != nil {
+			slog.Error("Failed to re-encrypt credentials", "err", err)
+			os.Exit(1)
+		}
+		slog.Info("Credentials re-encrypted", "accounts", n, "key_id", cfg.SecretKeys.ActiveKeyID())
+		return
+	}
+
+	telegramBot, err := bot.New(cfg, repo, accounts)
 	if err != nil {
 		slog.Error("Failed to initialize bot", "err", err)
 		os.Exit(1)
 	}
 
+	// Start background publish worker (drains queued targets, resumes after restarts)
+	telegramBot.StartWorker()
+
+	// Start scheduler that releases scheduled posts when they are due
+	telegramBot.StartScheduler()
+
+	// Start token monitor that refreshes account tokens and warns before they expire
+	telegramBot.StartTokenMonitor()
+
 	// Start bot in a separate goroutine
 	go func() {
 		slog.Info("Starting Telegram bot")
@@ -0,0 +1,289 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+	"trinity_bot/internal/oauth"
+	"trinity_bot/internal/storage"
+)
+
+// publisher returns the publisher of a target: the platform's publisher built from the environment's
+// credentials, or, for targets of a stored account, from a copy of the config carrying the account's.
+func (b *Bot) publisher(ctx context.Context, t storage.Target) (connectors.Publisher, error) {
+	factory, ok := b.publishers[t.Platform]
+	if !ok {
+		if factory, ok = connectors.Lookup(t.Platform); !ok {
+			return nil, connectors.ValidationError(t.Platform, fmt.Sprintf("publishing to %s is not supported yet", t.Platform))
+		}
+	}
+	if t.AccountID == nil {
+		return factory(b.config)
+	}
+	a, err := b.accounts.GetAccount(ctx, *t.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := accountConfig(b.config, a)
+	if err != nil {
+		return nil, err
+	}
+	return factory(cfg)
+}
+
+// accountConfig returns a copy of cfg whose credentials of the account's platform are replaced
+// by the account's, so connectors can be built the same way for every account.
+func accountConfig(cfg *config.Config, a *storage.Account) (*config.Config, error) {
+	c := *cfg
+	setting := func(key string, dst *string) {
+		if v := a.Settings[key]; v != "" {
+			*dst = v
+		}
+	}
+	switch a.Platform {
+	case "twitter":
+		// Accounts connected through OAuth 2.0 have no token secret
+		if a.AccessSecret == "" {
+			c.TwitterOAuth2Token = a.AccessToken
+		} else {
+			c.TwitterAccessToken, c.TwitterAccessSecret, c.TwitterOAuth2Token = a.AccessToken, a.AccessSecret, ""
+		}
+	case "pinterest":
+		c.PinterestAccessToken = a.AccessToken
+		c.PinterestBoardID = a.Settings["board_id"]
+	case "facebook":
+		c.FacebookAccessToken, c.FacebookPageID = a.AccessToken, a.ExternalID
+	case "instagram":
+		c.InstagramAccessToken, c.InstagramUserID = a.AccessToken, a.ExternalID
+	case "threads":
+		c.ThreadsAccessToken, c.ThreadsUserID = a.AccessToken, a.ExternalID
+	case "tiktok":
+		c.TikTokAccessToken = a.AccessToken
+	case "mastodon":
+		c.MastodonAccessToken = a.AccessToken
+		setting("instance_url", &c.MastodonInstanceURL)
+	case "bluesky":
+		c.BlueskyHandle, c.BlueskyAppPassword = a.ExternalID, a.AccessSecret
+		setting("pds_url", &c.BlueskyPDSURL)
+	case "linkedin":
+		c.LinkedInAccessToken, c.LinkedInOrganization = a.AccessToken, a.ExternalID
+	case "telegram_channel":
+		id, err := strconv.ParseInt(a.ExternalID, 10, 64)
+		if err != nil {
+			return nil, connectors.AuthError(a.Platform, fmt.Sprintf("account %d: invalid channel ID %q", a.ID, a.ExternalID))
+		}
+		c.TelegramChannelIDs = []int64{id}
+	default:
+		return nil, connectors.ValidationError(a.Platform, fmt.Sprintf("accounts of %s are not supported", a.Platform))
+	}
+	return &c, nil
+}
+
+// accountScope returns the owner whose accounts a user may list, select and remove: the user
+// themselves, or 0 (every owner) for ACCOUNT_ADMINS.
+func (b *Bot) accountScope(userID int64) int64 {
+	if slices.Contains(b.config.AccountAdmins, userID) {
+		return 0
+	}
+	return userID
+}
+
+// toggleTarget toggles a target of a post. Only accounts the post's owner may use can be selected.
+func (b *Bot) toggleTarget(ctx context.Context, postID int64, platform string, accountID *int64) (bool, error) {
+	post, err := b.repo.GetPost(ctx, postID)
+	if err != nil {
+		return false, err
+	}
+	return b.repo.ToggleTarget(ctx, postID, platform, accountID, b.accountScope(post.TelegramUserID))
+}
+
+// accountNames maps account IDs to their names for target labels. Errors leave the map empty.
+func (b *Bot) accountNames(ctx context.Context) map[int64]string {
+	accounts, err := b.accounts.ListAccounts(ctx, "", 0)
+	if err != nil {
+		slog.Error("List accounts error", "err", err)
+		return nil
+	}
+	names := make(map[int64]string, len(accounts))
+	for _, a := range accounts {
+		names[a.ID] = a.Name
+	}
+	return names
+}
+
+// targetLabel names a target for users: the platform, followed by the account name for targets of a stored account.
+func targetLabel(t storage.Target, names map[int64]string) string {
+	if t.AccountID == nil {
+		return platformLabel(t.Platform)
+	}
+	name, ok := names[*t.AccountID]
+	if !ok {
+		name = fmt.Sprintf("#%d", *t.AccountID)
+	}
+	return platformLabel(t.Platform) + " · " + name
+}
+
+// targetRows builds toggle buttons for every platform in storage.Platforms, marking the selected ones.
+// Platforms with accounts of the post's owner get one button per account instead of one for the
+// environment's credentials. data returns the callback data of a target key (see storage.TargetKey).
+func (b *Bot) targetRows(ctx context.Context, post *storage.Post, selected map[string]bool, data func(key string) string) ([][]tgbotapi.InlineKeyboardButton, error) {
+	accounts, err := b.accounts.ListAccounts(ctx, "", b.accountScope(post.TelegramUserID))
+	if err != nil {
+		return nil, err
+	}
+	byPlatform := make(map[string][]storage.Account)
+	for _, a := range accounts {
+		byPlatform[a.Platform] = append(byPlatform[a.Platform], a)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	add := func(key, label string) {
+		if selected[key] {
+			label = "✅ " + label
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, data(key)))
+		if len(row) == platformButtonsPerRow {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	for _, platform := range storage.Platforms {
+		if len(byPlatform[platform]) == 0 {
+			add(platform, platformLabel(platform))
+			continue
+		}
+		for _, a := range byPlatform[platform] {
+			add(storage.TargetKey(platform, &a.ID), platformLabel(platform)+" · "+a.Name)
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// handleAccountsCommand lists the user's stored platform accounts (all of them for ACCOUNT_ADMINS);
+// "/accounts remove <id>" deletes one.
+func (b *Bot) handleAccountsCommand(message *tgbotapi.Message) {
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+
+	fields := strings.Fields(message.CommandArguments())
+	if len(fields) > 0 {
+		if len(fields) != 2 || fields[0] != "remove" {
+			_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Usage: /accounts [remove <account_id>]")
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(fields[1], "#"), 10, 64)
+		if err != nil {
+			_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Invalid account id.")
+			return
+		}
+		if err := b.accounts.DeleteAccount(ctx, id, b.accountScope(message.From.ID)); err != nil {
+			if errors.Is(err, storage.ErrAccountInUse) {
+				_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Account #%d has published or queued posts and cannot be removed.", id))
+				return
+			}
+			slog.Error("Delete account error", "err", err, "account_id", id)
+			_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Account #%d not found.", id))
+			return
+		}
+		slog.Info("Account removed", "account_id", id, "user_id", message.From.ID)
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Account #%d removed.", id))
+		return
+	}
+
+	accounts, err := b.accounts.ListAccounts(ctx, "", b.accountScope(message.From.ID))
+	if err != nil {
+		slog.Error("List accounts error", "err", err)
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Error loading accounts.")
+		return
+	}
+	if len(accounts) == 0 {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "No accounts stored. Posts go to the accounts configured in the environment.")
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString("Accounts:\n")
+	for _, a := range accounts {
+		fmt.Fprintf(&sb, "#%d — %s · %s", a.ID, platformLabel(a.Platform), a.Name)
+		if a.ExternalID != "" {
+			fmt.Fprintf(&sb, " (%s)", a.ExternalID)
+		}
+		if a.TokenExpiresAt != nil {
+			fmt.Fprintf(&sb, " — token expires %s", b.formatTime(*a.TokenExpiresAt))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nUse /accounts remove <account_id> to remove one.")
+	_, _ = b.SendMessage(message.Chat.ID, sb.String())
+}
+
+// handleConnectCommand replies with the authorization link of a platform ("/connect <platform>").
+func (b *Bot) handleConnectCommand(message *tgbotapi.Message) {
+	if b.oauth == nil || b.config.PublicBaseURL == "" {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Connecting accounts is not configured (set PUBLIC_BASE_URL and the OAuth app credentials).")
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(message.CommandArguments()))
+	usage := "Usage: /connect <" + strings.Join(b.oauth.Names(), "|") + ">"
+	if provider == "" {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, usage)
+		return
+	}
+	link, err := b.oauth.AuthURL(provider, message.From.ID, message.Chat.ID)
+	if err != nil {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, usage)
+		return
+	}
+	m := tgbotapi.NewMessage(message.Chat.ID, "Open the link to authorize the bot. It is valid for 15 minutes and can be used once.")
+	m.ReplyToMessageID = message.MessageID
+	m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonURL("Authorize", link),
+	))
+	if _, err := b.api.Send(m); err != nil {
+		slog.Error("Send connect link error", "err", err)
+	}
+}
+
+// reportConnected tells the chat that started an authorization which accounts were stored.
+func (b *Bot) reportConnected(res oauth.Result) {
+	var text string
+	if res.Err != nil {
+		text = fmt.Sprintf("Connecting %s failed: %v", res.Provider, res.Err)
+	} else {
+		var sb strings.Builder
+		if len(res.Accounts) > 0 {
+			sb.WriteString("Connected accounts:")
+			for _, a := range res.Accounts {
+				fmt.Fprintf(&sb, "\n#%d — %s · %s", a.ID, platformLabel(a.Platform), a.Name)
+			}
+			sb.WriteString("\nThey now appear on the target keyboard.")
+		}
+		if len(res.Owned) > 0 {
+			if sb.Len() > 0 {
+				sb.WriteString("\n\n")
+			}
+			sb.WriteString("Not connected, already connected by another user:")
+			for _, a := range res.Owned {
+				fmt.Fprintf(&sb, "\n%s · %s", platformLabel(a.Platform), a.Name)
+			}
+			sb.WriteString("\nAsk that user or an account admin to remove it with /accounts remove first.")
+		}
+		text = sb.String()
+		slog.Info("Accounts connected", "provider", res.Provider, "user_id", res.UserID, "count", len(res.Accounts), "owned_by_others", len(res.Owned))
+	}
+	if _, err := b.SendMessage(res.ChatID, text); err != nil {
+		slog.Error("Send connect result error", "err", err)
+	}
+}
@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"trinity_bot/internal/connectors/pinterest"
+	"trinity_bot/internal/storage"
+)
+
+// maxBoardButtons caps the boards and sections offered in one keyboard (Telegram allows 100 buttons).
+const maxBoardButtons = 50
+
+// boardButtonsPerRow is the number of board or section buttons per keyboard row
+const boardButtonsPerRow = 2
+
+// pinterestTarget returns the key of the selected Pinterest target (see storage.TargetKey). A post
+// has at most one, see storage.PostRepository.ToggleTarget.
+func pinterestTarget(selected map[string]bool) (string, bool) {
+	for key, on := range selected {
+		if on && (key == "pinterest" || strings.HasPrefix(key, "pinterest:")) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// pinterestBoardRow returns the button opening the board picker when Pinterest is selected, or nil.
+func pinterestBoardRow(post *storage.Post, selected map[string]bool) []tgbotapi.InlineKeyboardButton {
+	if _, ok := pinterestTarget(selected); !ok {
+		return nil
+	}
+	label := "📌 Pinterest board"
+	if post.PinterestBoardID != "" {
+		label = "📌 Pinterest board ✅"
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("pin:%d", post.ID)))
+}
+
+// handleBoardCallback drives the board picker:
+// pin:<postID> lists the boards, pinb:<postID>:<boardID> the sections of a board (or saves a board
+// without sections), pins:<postID>:<boardID>:<sectionID> saves a board and section (empty for the whole board).
+func (b *Bot) handleBoardCallback(q *tgbotapi.CallbackQuery, action string, postID int64, parts []string) {
+	ctx, cancel := b.apiCtx()
+	defer cancel()
+	post, err := b.repo.GetPost(ctx, postID)
+	if err != nil || post.ChatID != q.Message.Chat.ID {
+		_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Post not found"))
+		return
+	}
+	cli, err := b.pinterestClient(ctx, postID)
+	if err != nil {
+		_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Pinterest is not configured"))
+		return
+	}
+
+	switch {
+	case action == "pin":
+		boards, err := cli.ListBoards(ctx)
+		if err != nil {
+			slog.Error("List Pinterest boards error", "err", err, "post_id", postID)
+			_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Error loading boards"))
+			return
+		}
+		if len(boards) == 0 {
+			_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "No Pinterest boards found"))
+			return
+		}
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, bd := range boards {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(bd.Name, fmt.Sprintf("pinb:%d:%s", postID, bd.ID)))
+		}
+		m := tgbotapi.NewMessage(q.Message.Chat.ID, fmt.Sprintf("Choose the Pinterest board for post #%d.", postID))
+		m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttonRows(buttons)...)
+		_, _ = b.api.Send(m)
+		_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Boards"))
+	case action == "pinb" && len(parts) == 3:
+		boardID := parts[2]
+		sections, err := cli.ListSections(ctx, boardID)
+		if err != nil {
+			slog.Error("List Pinterest board sections error", "err", err, "post_id", postID, "board_id", boardID)
+			_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Error loading sections"))
+			return
+		}
+		if len(sections) == 0 {
+			b.saveBoard(q, postID, boardID, "")
+			return
+		}
+		buttons := []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData("Whole board", fmt.Sprintf("pins:%d:%s:", postID, boardID)),
+		}
+		for _, s := range sections {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(s.Name, fmt.Sprintf("pins:%d:%s:%s", postID, boardID, s.ID)))
+		}
+		edit := tgbotapi.NewEditMessageTextAndMarkup(q.Message.Chat.ID, q.Message.MessageID,
+			fmt.Sprintf("Choose a section for post #%d.", postID), tgbotapi.NewInlineKeyboardMarkup(buttonRows(buttons)...))
+		_, _ = b.api.Request(edit)
+		_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Sections"))
+	case action == "pins" && len(parts) == 4:
+		b.saveBoard(q, postID, parts[2], parts[3])
+	default:
+		_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Invalid board"))
+	}
+}
+
+// pinterestClient returns a client for the post's selected Pinterest account, or the environment's
+// credentials when it has none.
+func (b *Bot) pinterestClient(ctx context.Context, postID int64) (*pinterest.Client, error) {
+	token := b.config.PinterestAccessToken
+	selected, err := b.repo.ListTargets(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := pinterestTarget(selected); ok {
+		_, accountID, err := storage.ParseTargetKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if accountID != nil {
+			a, err := b.accounts.GetAccount(ctx, *accountID)
+			if err != nil {
+				return nil, err
+			}
+			token = a.AccessToken
+		}
+	}
+	return pinterest.New(pinterest.Credentials{AccessToken: token})
+}
+
+// saveBoard stores the chosen board and section and replaces the picker with a confirmation.
+func (b *Bot) saveBoard(q *tgbotapi.CallbackQuery, postID int64, boardID, sectionID string) {
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+	if err := b.repo.SetPinterestBoard(ctx, postID, boardID, sectionID); err != nil {
+		slog.Error("Set Pinterest board error", "err", err, "post_id", postID)
+		_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Error"))
+		return
+	}
+	detail := "board=" + boardID
+	if sectionID != "" {
+		detail += " section=" + sectionID
+	}
+	_ = b.repo.AddLog(ctx, postID, ptr("pinterest"), "board_selected", detail)
+	edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, fmt.Sprintf("Pinterest board saved for post #%d.", postID))
+	_, _ = b.api.Request(edit)
+	_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Board saved"))
+}
+
+// buttonRows lays out at most maxBoardButtons buttons in rows of boardButtonsPerRow.
+func buttonRows(buttons []tgbotapi.InlineKeyboardButton) [][]tgbotapi.InlineKeyboardButton {
+	if len(buttons) > maxBoardButtons {
+		buttons = buttons[:maxBoardButtons]
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for len(buttons) > 0 {
+		n := min(boardButtonsPerRow, len(buttons))
+		rows = append(rows, buttons[:n])
+		buttons = buttons[n:]
+	}
+	return rows
+}
@@ -2,6 +2,7 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -13,6 +14,9 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+	"trinity_bot/internal/media"
+	"trinity_bot/internal/oauth"
 	"trinity_bot/internal/storage"
 )
 
@@ -24,13 +28,27 @@ type Bot struct {
 	server   *http.Server // For webhook mode
 	stopChan chan struct{}
 	repo     storage.PostRepository
+	accounts storage.AccountRepository
+
+	wake       chan struct{}  // nudges the publish worker after enqueueing
+	publishSem chan struct{}  // bounds concurrent platform publishes (PUBLISH_CONCURRENCY)
+	wg         sync.WaitGroup // background goroutines (publish worker, scheduler)
+
+	publishCtx  context.Context    // parent of every publish; canceled by Stop
+	stopPublish context.CancelFunc // cancels publishCtx
+
+	oauth *oauth.Flow   // account linking (/connect) and token refresh; nil without OAuth apps
+	media *media.Server // signed media URLs for platforms that pull media; nil without MEDIA_BASE_URL
+
+	// publisher factories that need the bot itself and therefore are not in the connectors registry
+	publishers map[string]connectors.Factory
 
 	mu       sync.Mutex
 	sessions map[int64]*PostSession // key: chatID
 }
 
 // New creates a new bot instance
-func New(cfg *config.Config, repo storage.PostRepository) (*Bot, error) {
+func New(cfg *config.Config, repo storage.PostRepository, accounts storage.AccountRepository) (*Bot, error) {
 	// Initialize Telegram API
 	api, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
 	if err != nil {
@@ -42,11 +60,41 @@ func New(cfg *config.Config, repo storage.PostRepository) (*Bot, error) {
 
 	// Create a bot instance
 	bot := &Bot{
-		api:      api,
-		config:   cfg,
-		stopChan: make(chan struct{}),
-		repo:     repo,
-		sessions: make(map[int64]*PostSession),
+		api:        api,
+		config:     cfg,
+		stopChan:   make(chan struct{}),
+		repo:       repo,
+		accounts:   accounts,
+		sessions:   make(map[int64]*PostSession),
+		wake:       make(chan struct{}, 1),
+		publishSem: make(chan struct{}, cfg.PublishConcurrency),
+	}
+	bot.publishCtx, bot.stopPublish = context.WithCancel(context.Background())
+	bot.publishers = map[string]connectors.Factory{
+		"telegram_channel": func(cfg *config.Config) (connectors.Publisher, error) {
+			return &channelPublisher{api: api, channels: cfg.TelegramChannelIDs}, nil
+		},
+	}
+
+	if providers := oauth.Providers(cfg); len(providers) > 0 {
+		bot.oauth = oauth.New(providers, cfg.PublicBaseURL+oauth.CallbackPath, accounts, bot.reportConnected)
+		if cfg.PublicBaseURL != "" {
+			http.Handle(oauth.CallbackPath, bot.oauth)
+		}
+	}
+
+	if cfg.MediaBaseURL != "" {
+		bot.media, err = media.New(cfg.MediaCacheDir, cfg.MediaBaseURL, cfg.MediaSigningKey, cfg.MediaURLTTL)
+		if err != nil {
+			return nil, err
+		}
+		http.Handle(media.Path, bot.media)
+	}
+
+	for _, p := range storage.Platforms {
+		if _, ok := connectors.Lookup(p); !ok && bot.publishers[p] == nil {
+			slog.Warn("No connector registered for platform", "platform", p)
+		}
 	}
 
 	slog.Info("Authorized on Telegram", "username", api.Self.UserName)
@@ -68,6 +116,11 @@ func (b *Bot) startLongPolling() error {
 		slog.Warn("Failed to delete webhook before polling", "err", err)
 	}
 
+	// OAuth callbacks and the media endpoint still need the HTTP server
+	if b.config.MediaBaseURL != "" {
+		b.serveHTTP()
+	}
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = b.config.UpdateTimeout
 
@@ -108,13 +161,7 @@ func (b *Bot) startWebhook() error {
 	updates := b.api.ListenForWebhook("/" + b.api.Token)
 	b.updates = updates
 
-	// Start webhook server
-	go func() {
-		slog.Info("Starting webhook server", "port", b.config.WebhookPort)
-		if err := http.ListenAndServe(":"+b.config.WebhookPort, nil); err != nil {
-			slog.Error("Webhook server error", "err", err)
-		}
-	}()
+	b.serveHTTP()
 
 	for {
 		select {
@@ -126,16 +173,30 @@ func (b *Bot) startWebhook() error {
 	}
 }
 
-// Stop stops the bot
+// serveHTTP starts the HTTP server for the webhook, OAuth callbacks and media, which are registered on
+// http.DefaultServeMux.
+func (b *Bot) serveHTTP() {
+	b.server = &http.Server{Addr: ":" + b.config.WebhookPort, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		slog.Info("Starting HTTP server", "port", b.config.WebhookPort)
+		if err := b.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("HTTP server error", "err", err)
+		}
+	}()
+}
+
+// Stop stops the bot and waits for in-flight background work to finish
 func (b *Bot) Stop() {
 	if b.config.WebhookURL != "" {
 		// Remove webhook
 		_, _ = b.api.Request(tgbotapi.DeleteWebhookConfig{})
-		if b.server != nil {
-			_ = b.server.Close()
-		}
+	}
+	if b.server != nil {
+		_ = b.server.Close()
 	}
 	close(b.stopChan)
+	b.stopPublish()
+	b.wg.Wait()
 }
 
 // SendMessage sends a message to the given chat
@@ -156,11 +217,16 @@ func (b *Bot) dbCtx() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 3*time.Second)
 }
 
+// helper: context with timeout for handlers that also call platform APIs
+func (b *Bot) apiCtx() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
 // downloadTelegramFile downloads a file by its Telegram FileID and returns bytes and content-type.
 func (b *Bot) downloadTelegramFile(ctx context.Context, fileID string) ([]byte, string, error) {
 	f, err := b.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
 	if err != nil {
-		return nil, "", fmt.Errorf("get file: %w", err)
+		return nil, "", fmt.Errorf("get file: %w", connectors.StripURL(err))
 	}
 	if f.FilePath == "" {
 		return nil, "", fmt.Errorf("empty file path for fileID %s", fileID)
@@ -168,40 +234,28 @@ func (b *Bot) downloadTelegramFile(ctx context.Context, fileID string) ([]byte,
 	raw := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", url.PathEscape(b.api.Token), f.FilePath)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
 	if err != nil {
-		return nil, "", err
+		return nil, "", errors.New("telegram file download: invalid file path")
 	}
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, "", err
+		return nil, "", connectors.NetworkError("telegram", fmt.Errorf("telegram file download: %w", connectors.StripURL(err)))
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("telegram file download status %d", resp.StatusCode)
+		return nil, "", connectors.StatusError("telegram", resp, fmt.Errorf("telegram file download status %d", resp.StatusCode))
 	}
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, "", err
+		return nil, "", connectors.NetworkError("telegram", fmt.Errorf("telegram file download: %w", connectors.StripURL(err)))
 	}
+	// The file server usually answers application/octet-stream; connectors need the real type
 	ctype := resp.Header.Get("Content-Type")
-	if ctype == "" {
-		ctype = "application/octet-stream"
+	if ctype == "" || ctype == "application/octet-stream" {
+		ctype = http.DetectContentType(data)
 	}
 	return data, ctype, nil
 }
 
-// getTelegramFileURL returns a publicly accessible URL for a Telegram fileID.
-func (b *Bot) getTelegramFileURL(fileID string) (string, error) {
-	f, err := b.api.GetFile(tgbotapi.FileConfig{FileID: fileID})
-	if err != nil {
-		return "", fmt.Errorf("get file: %w", err)
-	}
-	if f.FilePath == "" {
-		return "", fmt.Errorf("empty file path for fileID %s", fileID)
-	}
-	raw := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", url.PathEscape(b.api.Token), f.FilePath)
-	return raw, nil
-}
-
 type PostSession struct {
 	PostID     int64
 	Step       string // compose | confirm
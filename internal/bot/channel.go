@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"trinity_bot/internal/connectors"
+)
+
+// maxCaptionLength is Telegram's caption limit; longer texts are sent as a separate message.
+const maxCaptionLength = 1024
+
+// channelPublisher cross-posts to Telegram channels through the bot's own API client. Media is
+// resent by file ID, so nothing is downloaded.
+type channelPublisher struct {
+	api      *tgbotapi.BotAPI
+	channels []int64
+}
+
+// Publish sends the post to every configured channel. The external ID lists the sent message
+// IDs per channel: "<channel>:<id>,<id>;<channel>:<id>".
+func (p *channelPublisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	if len(p.channels) == 0 {
+		return "", connectors.AuthError("telegram_channel", "TELEGRAM_CHANNEL_IDS not configured")
+	}
+	if post.Text == "" && len(post.Media) == 0 {
+		return "", connectors.ValidationError("telegram_channel", "post has no text or media")
+	}
+	var sent []string
+	for _, ch := range p.channels {
+		ids, err := p.sendTo(ctx, ch, post)
+		if err != nil {
+			if len(sent) == 0 {
+				return "", err
+			}
+			// Retrying would post to the channels that already succeeded a second time
+			return "", connectors.ValidationError("telegram_channel",
+				fmt.Sprintf("channel %d: %v (already posted: %s)", ch, err, strings.Join(sent, ";")))
+		}
+		sent = append(sent, fmt.Sprintf("%d:%s", ch, strings.Join(ids, ",")))
+	}
+	return strings.Join(sent, ";"), nil
+}
+
+// sendTo posts the text and media to one channel and returns the message IDs.
+func (p *channelPublisher) sendTo(ctx context.Context, chatID int64, post *connectors.Post) ([]string, error) {
+	var msgs []tgbotapi.Message
+	caption := post.Text
+	separateText := len([]rune(caption)) > maxCaptionLength
+	if separateText {
+		caption = ""
+	}
+
+	switch len(post.Media) {
+	case 0:
+		separateText = true
+	case 1:
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var c tgbotapi.Chattable
+		file := tgbotapi.FileID(post.Media[0].FileID)
+		if post.Media[0].Type == "video" {
+			v := tgbotapi.NewVideo(chatID, file)
+			v.Caption = caption
+			c = v
+		} else {
+			ph := tgbotapi.NewPhoto(chatID, file)
+			ph.Caption = caption
+			c = ph
+		}
+		m, err := p.api.Send(c)
+		if err != nil {
+			return nil, telegramError(err)
+		}
+		msgs = append(msgs, m)
+	default:
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// The album caption is the caption of its first item
+		items := make([]interface{}, 0, len(post.Media))
+		for i, it := range post.Media {
+			file := tgbotapi.FileID(it.FileID)
+			if it.Type == "video" {
+				v := tgbotapi.NewInputMediaVideo(file)
+				if i == 0 {
+					v.Caption = caption
+				}
+				items = append(items, v)
+			} else {
+				ph := tgbotapi.NewInputMediaPhoto(file)
+				if i == 0 {
+					ph.Caption = caption
+				}
+				items = append(items, ph)
+			}
+		}
+		ms, err := p.api.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, items))
+		if err != nil {
+			return nil, telegramError(err)
+		}
+		msgs = append(msgs, ms...)
+	}
+
+	if separateText && post.Text != "" {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		m, err := p.api.Send(tgbotapi.NewMessage(chatID, post.Text))
+		if err != nil {
+			return nil, telegramError(err)
+		}
+		msgs = append(msgs, m)
+	}
+
+	ids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, strconv.Itoa(m.MessageID))
+	}
+	return ids, nil
+}
+
+// telegramError classifies a Bot API error. 403 means the bot is not an admin of the channel.
+// Transport errors lose their URL, which carries the bot token (see connectors.NetworkError).
+func telegramError(err error) error {
+	var te *tgbotapi.Error
+	if !errors.As(err, &te) {
+		return connectors.NetworkError("telegram_channel", err)
+	}
+	e := &connectors.Error{Platform: "telegram_channel", Kind: connectors.KindForStatus(te.Code), StatusCode: te.Code, Err: err}
+	if te.RetryAfter > 0 {
+		e.Kind = connectors.KindRateLimited
+		e.RetryAfter = time.Duration(te.RetryAfter) * time.Second
+	}
+	return e
+}
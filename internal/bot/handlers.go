@@ -2,18 +2,18 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
-	"trinity_bot/internal/connectors/facebook"
-	"trinity_bot/internal/connectors/instagram"
-	"trinity_bot/internal/connectors/pinterest"
-	"trinity_bot/internal/connectors/twitter"
 	"trinity_bot/internal/storage"
+	"trinity_bot/pkg/utils"
 )
 
 // handleUpdate processes a single update from Telegram
@@ -103,6 +103,15 @@ func (b *Bot) handleMessage(message *tgbotapi.Message) {
 				_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Please send photos/videos and text in captions, then press Confirm.")
 			}
 			return
+		case "schedule":
+			ctx, cancel := b.apiCtx()
+			defer cancel()
+			reply, ok := b.schedulePost(ctx, message.Chat.ID, s.PostID, message.Text)
+			if ok {
+				b.clearSession(message.Chat.ID)
+			}
+			_, _ = b.SendReply(message.Chat.ID, message.MessageID, reply)
+			return
 		}
 	}
 
@@ -172,6 +181,20 @@ func (b *Bot) handleCommand(message *tgbotapi.Message) {
 		b.handleHelpCommand(message)
 	case "post":
 		b.handlePostCommand(message)
+	case "schedule":
+		b.handleScheduleCommand(message)
+	case "scheduled":
+		b.handleScheduledCommand(message)
+	case "unschedule":
+		b.handleUnscheduleCommand(message)
+	case "alt":
+		b.handleAltCommand(message)
+	case "link":
+		b.handleLinkCommand(message)
+	case "accounts":
+		b.handleAccountsCommand(message)
+	case "connect":
+		b.handleConnectCommand(message)
 	default:
 		_, err := b.SendReply(message.Chat.ID, message.MessageID, "Unknown command. Try /help")
 		if err != nil {
@@ -185,9 +208,11 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 	slog.Info("Callback received", "username", query.From.UserName, "user_id", query.From.ID, "data", query.Data)
 
 	// Expect formats:
-	// tgl:<postID>:<platform>
+	// tgl:<postID>:<platform>[:<accountID>]
 	// pub:<postID>
+	// sch:<postID>
 	// can:<postID>
+	// pin:<postID>, pinb:..., pins:... (Pinterest board picker)
 	parts := strings.Split(query.Data, ":")
 	if len(parts) < 2 {
 		_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Invalid action"))
@@ -207,15 +232,21 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 	}
 
 	switch action {
+	case "pin", "pinb", "pins":
+		b.handleBoardCallback(query, action, postID64, parts)
 	case "tgl":
-		if len(parts) != 3 {
+		if len(parts) != 3 && len(parts) != 4 {
+			_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Invalid toggle"))
+			return
+		}
+		platform, accountID, err := storage.ParseTargetKey(strings.Join(parts[2:], ":"))
+		if err != nil {
 			_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Invalid toggle"))
 			return
 		}
-		platform := parts[2]
 		ctx, cancel := b.dbCtx()
 		defer cancel()
-		enabled, err := b.repo.ToggleTarget(ctx, postID64, platform)
+		enabled, err := b.toggleTarget(ctx, postID64, platform, accountID)
 		if err != nil {
 			slog.Error("Toggle target error", "err", err, "post_id", postID64, "platform", platform)
 			_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Error"))
@@ -235,24 +266,42 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 		}
 		_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, fmt.Sprintf("%s %s", platform, label)))
 	case "pub":
-		ctx, cancel := b.dbCtx()
+		ctx, cancel := b.apiCtx()
 		defer cancel()
-		if err := b.repo.SetPostStatus(ctx, postID64, "queued"); err != nil {
+		// Publishing now replaces any schedule handed to the platforms
+		if err := b.cancelNativeSchedules(ctx, postID64); err != nil {
+			slog.Error("Cancel native schedule error", "err", err, "post_id", postID64)
+			_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Error"))
+			_, _ = b.api.Send(tgbotapi.NewMessage(query.Message.Chat.ID, fmt.Sprintf("Could not cancel the scheduled copy of post #%d on %v.", postID64, err)))
+			return
+		}
+		n, err := b.repo.EnqueuePost(ctx, postID64)
+		if err != nil {
 			slog.Error("Queue post error", "err", err, "post_id", postID64)
 			_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Error"))
 			return
 		}
-		_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Queued"))
-		// Attempt immediate publish for selected platforms (Twitter only for now)
-		if err := b.publishSelected(ctx, postID64); err != nil {
-			slog.Error("Publish selected error", "err", err, "post_id", postID64)
-			_, _ = b.api.Send(tgbotapi.NewMessage(query.Message.Chat.ID, fmt.Sprintf("Post #%d queued, publish attempt failed: %v", postID64, err)))
-		} else {
-			_, _ = b.api.Send(tgbotapi.NewMessage(query.Message.Chat.ID, fmt.Sprintf("Post #%d published to selected platforms (where applicable).", postID64)))
+		if n == 0 {
+			_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Select at least one platform"))
+			return
 		}
+		b.notifyWorker()
+		_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Queued"))
+		_, _ = b.api.Send(tgbotapi.NewMessage(query.Message.Chat.ID, fmt.Sprintf("Post #%d queued for publishing.", postID64)))
+	case "sch":
+		b.setSession(query.Message.Chat.ID, &PostSession{PostID: postID64, Step: "schedule"})
+		_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Schedule"))
+		prompt := fmt.Sprintf("Send the date and time to publish post #%d (YYYY-MM-DD HH:MM, %s).", postID64, b.config.Timezone)
+		_, _ = b.api.Send(tgbotapi.NewMessage(query.Message.Chat.ID, prompt))
 	case "can":
-		ctx, cancel := b.dbCtx()
+		ctx, cancel := b.apiCtx()
 		defer cancel()
+		if err := b.cancelNativeSchedules(ctx, postID64); err != nil {
+			slog.Error("Cancel native schedule error", "err", err, "post_id", postID64)
+			_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Error"))
+			_, _ = b.api.Send(tgbotapi.NewMessage(query.Message.Chat.ID, fmt.Sprintf("Could not cancel the scheduled copy of post #%d on %v.", postID64, err)))
+			return
+		}
 		if err := b.repo.SetPostStatus(ctx, postID64, "canceled"); err != nil {
 			slog.Error("Cancel post error", "err", err, "post_id", postID64)
 			_, _ = b.api.Request(tgbotapi.NewCallback(query.ID, "Error"))
@@ -305,18 +354,22 @@ func (b *Bot) handlePostSetupCallback(q *tgbotapi.CallbackQuery) {
 		return
 	}
 	action := parts[1]
-	// Expect formats: ps:toggle:<postID>:<platform>, ps:confirm:<postID>, ps:cancel:<postID>
+	// Expect formats: ps:toggle:<postID>:<platform>[:<accountID>], ps:confirm:<postID>, ps:cancel:<postID>
 	switch action {
 	case "toggle":
-		if len(parts) != 4 {
+		if len(parts) != 4 && len(parts) != 5 {
 			_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Invalid toggle"))
 			return
 		}
 		postID, _ := strconv.ParseInt(parts[2], 10, 64)
-		platform := parts[3]
+		platform, accountID, err := storage.ParseTargetKey(strings.Join(parts[3:], ":"))
+		if err != nil {
+			_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Invalid toggle"))
+			return
+		}
 		ctx, cancel := b.dbCtx()
 		defer cancel()
-		if _, err := b.repo.ToggleTarget(ctx, postID, platform); err != nil {
+		if _, err := b.toggleTarget(ctx, postID, platform, accountID); err != nil {
 			slog.Error("toggle (setup) error", "err", err)
 		}
 		kb, _ := b.buildConfirmTargetsMarkup(ctx, postID)
@@ -331,13 +384,18 @@ func (b *Bot) handlePostSetupCallback(q *tgbotapi.CallbackQuery) {
 		postID, _ := strconv.ParseInt(parts[2], 10, 64)
 		ctx, cancel := b.dbCtx()
 		defer cancel()
-		_ = b.repo.SetPostStatus(ctx, postID, "queued")
-		if err := b.publishSelected(ctx, postID); err != nil {
-			slog.Error("publish (confirm) error", "err", err, "post_id", postID)
-			_, _ = b.api.Send(tgbotapi.NewMessage(q.Message.Chat.ID, fmt.Sprintf("Publish failed: %v", err)))
-		} else {
-			_, _ = b.api.Send(tgbotapi.NewMessage(q.Message.Chat.ID, "Published to selected platforms."))
+		n, err := b.repo.EnqueuePost(ctx, postID)
+		if err != nil {
+			slog.Error("queue (confirm) error", "err", err, "post_id", postID)
+			_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Error"))
+			return
 		}
+		if n == 0 {
+			_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Select at least one platform"))
+			return
+		}
+		b.notifyWorker()
+		_, _ = b.api.Send(tgbotapi.NewMessage(q.Message.Chat.ID, fmt.Sprintf("Post #%d queued for publishing.", postID)))
 		b.clearSession(q.Message.Chat.ID)
 		_, _ = b.api.Request(tgbotapi.NewCallback(q.ID, "Done"))
 	case "cancel":
@@ -399,6 +457,13 @@ Available commands:
 /start - Start the bot
 /help - Show this help message
 /post - Create a new post: send photos/videos (up to 10) and/or text → select platforms → confirm
+/schedule <post_id> <YYYY-MM-DD HH:MM> - Schedule (or reschedule) a post
+/scheduled - List scheduled posts
+/unschedule <post_id> - Cancel a schedule and keep the post as a draft
+/alt <n> <text> - While composing, set the description (alt text) of the n-th photo/video
+/link [post_id] <url|none> - Set or remove the destination link (used by Pinterest) of the post being composed or of a draft
+/accounts [remove <account_id>] - List the stored platform accounts or remove one
+/connect <platform> - Link accounts of twitter, pinterest, meta (Facebook Pages and Instagram) or linkedin
 Send a text message or a photo with caption to create a draft post.
 Use the buttons to select platforms and publish.
 `
@@ -408,342 +473,313 @@ Use the buttons to select platforms and publish.
 	}
 }
 
-// buildTargetsMarkup builds inline keyboard with platform toggles and actions
-func (b *Bot) buildTargetsMarkup(ctx context.Context, postID int64) (tgbotapi.InlineKeyboardMarkup, error) {
-	// Caller provides context (usually from b.dbCtx)
-	selected, err := b.repo.ListTargets(ctx, postID)
+func (b *Bot) handleScheduleCommand(message *tgbotapi.Message) {
+	fields := strings.Fields(message.CommandArguments())
+	if len(fields) < 2 {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Usage: /schedule <post_id> <YYYY-MM-DD HH:MM>")
+		return
+	}
+	postID, err := strconv.ParseInt(strings.TrimPrefix(fields[0], "#"), 10, 64)
 	if err != nil {
-		return tgbotapi.InlineKeyboardMarkup{}, err
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Invalid post id.")
+		return
 	}
+	ctx, cancel := b.apiCtx()
+	defer cancel()
+	reply, _ := b.schedulePost(ctx, message.Chat.ID, postID, strings.Join(fields[1:], " "))
+	_, _ = b.SendReply(message.Chat.ID, message.MessageID, reply)
+}
 
-	btn := func(name, key string) tgbotapi.InlineKeyboardButton {
-		label := name
-		if selected[key] {
-			label = "✅ " + name
+func (b *Bot) handleScheduledCommand(message *tgbotapi.Message) {
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+	posts, err := b.repo.ListScheduledPosts(ctx, message.Chat.ID)
+	if err != nil {
+		slog.Error("List scheduled posts error", "err", err)
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Error loading scheduled posts.")
+		return
+	}
+	if len(posts) == 0 {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "No scheduled posts.")
+		return
+	}
+	names := b.accountNames(ctx)
+	var sb strings.Builder
+	sb.WriteString("Scheduled posts:\n")
+	for _, p := range posts {
+		var platforms []string
+		if targets, err := b.repo.ListPostTargets(ctx, p.ID); err == nil {
+			for _, t := range targets {
+				name := targetLabel(t, names)
+				if t.Status == "scheduled" {
+					name += " (native)"
+				}
+				platforms = append(platforms, name)
+			}
+		}
+		fmt.Fprintf(&sb, "#%d — %s — %s", p.ID, b.formatTime(*p.ScheduledAt), strings.Join(platforms, ", "))
+		if t := strings.TrimSpace(p.TextContent); t != "" {
+			fmt.Fprintf(&sb, " — %s", utils.TruncateText(strings.ReplaceAll(t, "\n", " "), 40))
 		}
-		return tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("tgl:%d:%s", postID, key))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nUse /schedule <post_id> <time> to reschedule or /unschedule <post_id> to cancel.")
+	for _, chunk := range utils.SplitMessageByLimit(sb.String(), 4096) {
+		_, _ = b.SendMessage(message.Chat.ID, chunk)
 	}
-
-	row1 := tgbotapi.NewInlineKeyboardRow(
-		btn("Twitter", "twitter"),
-		btn("Pinterest", "pinterest"),
-		btn("Facebook", "facebook"),
-	)
-	row2 := tgbotapi.NewInlineKeyboardRow(
-		btn("Instagram", "instagram"),
-		btn("TikTok", "tiktok"),
-	)
-	actions := tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🚀 Publish", fmt.Sprintf("pub:%d", postID)),
-		tgbotapi.NewInlineKeyboardButtonData("✖️ Cancel", fmt.Sprintf("can:%d", postID)),
-	)
-
-	return tgbotapi.NewInlineKeyboardMarkup(row1, row2, actions), nil
 }
 
-// buildSetupTargetsMarkup is like buildTargetsMarkup but uses ps:toggle callbacks and appends Next/Cancel row.
-func (b *Bot) buildSetupTargetsMarkup(ctx context.Context, postID int64) (tgbotapi.InlineKeyboardMarkup, error) {
-	selected, err := b.repo.ListTargets(ctx, postID)
+func (b *Bot) handleUnscheduleCommand(message *tgbotapi.Message) {
+	postID, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(message.CommandArguments()), "#"), 10, 64)
 	if err != nil {
-		return tgbotapi.InlineKeyboardMarkup{}, err
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Usage: /unschedule <post_id>")
+		return
 	}
-	btn := func(name, key string) tgbotapi.InlineKeyboardButton {
-		label := name
-		if selected[key] {
-			label = "✅ " + name
-		}
-		return tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("ps:toggle:%d:%s", postID, key))
+	ctx, cancel := b.apiCtx()
+	defer cancel()
+	p, err := b.repo.GetPost(ctx, postID)
+	if err != nil || p.ChatID != message.Chat.ID {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Post #%d not found.", postID))
+		return
 	}
-	row1 := tgbotapi.NewInlineKeyboardRow(
-		btn("Twitter", "twitter"), btn("Pinterest", "pinterest"), btn("Facebook", "facebook"),
-	)
-	row2 := tgbotapi.NewInlineKeyboardRow(
-		btn("Instagram", "instagram"), btn("TikTok", "tiktok"),
-	)
-	next := tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Next ▶️ Media", fmt.Sprintf("ps:media:%d", postID)),
-		tgbotapi.NewInlineKeyboardButtonData("Cancel", fmt.Sprintf("ps:cancel:%d", postID)),
-	)
-	return tgbotapi.NewInlineKeyboardMarkup(row1, row2, next), nil
+	if p.Status != "scheduled" {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Post #%d is not scheduled.", postID))
+		return
+	}
+	if err := b.cancelNativeSchedules(ctx, postID); err != nil {
+		slog.Error("Cancel native schedule error", "err", err, "post_id", postID)
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Could not cancel the scheduled copy on %v. The schedule is unchanged.", err))
+		return
+	}
+	if err := b.repo.UnschedulePost(ctx, postID); err != nil {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Post #%d is not scheduled.", postID))
+		return
+	}
+	_ = b.repo.AddLog(ctx, postID, nil, "unscheduled", "")
+	_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Schedule for post #%d canceled. The post is a draft again.", postID))
 }
 
-// buildConfirmTargetsMarkup shows toggles and Confirm/Cancel.
-func (b *Bot) buildConfirmTargetsMarkup(ctx context.Context, postID int64) (tgbotapi.InlineKeyboardMarkup, error) {
-	selected, err := b.repo.ListTargets(ctx, postID)
-	if err != nil {
-		return tgbotapi.InlineKeyboardMarkup{}, err
+// handleAltCommand sets the alt text of an attachment of the post being composed.
+func (b *Bot) handleAltCommand(message *tgbotapi.Message) {
+	s, ok := b.getSession(message.Chat.ID)
+	if !ok || s.Step != "compose" {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Use /alt while composing a post with /post.")
+		return
+	}
+	fields := strings.SplitN(strings.TrimSpace(message.CommandArguments()), " ", 2)
+	n, err := strconv.Atoi(fields[0])
+	if err != nil || n < 1 || len(fields) < 2 || strings.TrimSpace(fields[1]) == "" {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Usage: /alt <n> <description>")
+		return
 	}
-	btn := func(name, key string) tgbotapi.InlineKeyboardButton {
-		label := name
-		if selected[key] {
-			label = "✅ " + name
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+	if err := b.repo.SetMediaDescription(ctx, s.PostID, n-1, strings.TrimSpace(fields[1])); err != nil {
+		slog.Error("Set media description error", "err", err, "post_id", s.PostID)
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("No attachment #%d in this post.", n))
+		return
+	}
+	_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Description set for attachment #%d.", n))
+}
+
+// handleLinkCommand sets the destination link of the post being composed, or of the given post.
+func (b *Bot) handleLinkCommand(message *tgbotapi.Message) {
+	fields := strings.Fields(message.CommandArguments())
+	var postID int64
+	switch len(fields) {
+	case 1:
+		s, ok := b.getSession(message.Chat.ID)
+		if !ok || s.Step != "compose" {
+			_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Use /link while composing a post with /post, or give the post id: /link <post_id> <url>")
+			return
+		}
+		postID = s.PostID
+	case 2:
+		id, err := strconv.ParseInt(strings.TrimPrefix(fields[0], "#"), 10, 64)
+		if err != nil {
+			_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Invalid post id.")
+			return
 		}
-		return tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("ps:toggle:%d:%s", postID, key))
+		postID = id
+		fields = fields[1:]
+	default:
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Usage: /link [post_id] <url|none>")
+		return
 	}
-	row1 := tgbotapi.NewInlineKeyboardRow(
-		btn("Twitter", "twitter"), btn("Pinterest", "pinterest"), btn("Facebook", "facebook"),
-	)
-	row2 := tgbotapi.NewInlineKeyboardRow(
-		btn("Instagram", "instagram"), btn("TikTok", "tiktok"),
-	)
-	actions := tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Confirm ✅", fmt.Sprintf("ps:confirm:%d", postID)),
-		tgbotapi.NewInlineKeyboardButtonData("Cancel", fmt.Sprintf("ps:cancel:%d", postID)),
-	)
-	return tgbotapi.NewInlineKeyboardMarkup(row1, row2, actions), nil
+	link := fields[0]
+	if strings.EqualFold(link, "none") {
+		link = ""
+	} else if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "The link must be an http(s) URL.")
+		return
+	}
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+	if p, err := b.repo.GetPost(ctx, postID); err != nil || p.ChatID != message.Chat.ID {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Post #%d not found.", postID))
+		return
+	}
+	if err := b.repo.SetPostLink(ctx, postID, link); err != nil {
+		slog.Error("Set post link error", "err", err, "post_id", postID)
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, "Error saving the link. Please try again.")
+		return
+	}
+	if link == "" {
+		_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Link removed from post #%d.", postID))
+		return
+	}
+	_, _ = b.SendReply(message.Chat.ID, message.MessageID, fmt.Sprintf("Link set for post #%d.", postID))
 }
 
-// publishSelected publishes the post to all currently selected targets.
-func (b *Bot) publishSelected(ctx context.Context, postID int64) error {
-	selections, err := b.repo.ListTargets(ctx, postID)
+// schedulePost validates the requested time and schedules a post owned by the chat.
+// Returns the reply for the user and whether the post was scheduled.
+func (b *Bot) schedulePost(ctx context.Context, chatID, postID int64, when string) (string, bool) {
+	at, err := utils.ParseDateTime(when, b.config.Timezone)
 	if err != nil {
-		return err
+		return "Invalid date/time: " + err.Error(), false
+	}
+	if !at.After(time.Now()) {
+		return "The scheduled time must be in the future.", false
 	}
 	post, err := b.repo.GetPost(ctx, postID)
+	if err != nil || post.ChatID != chatID {
+		return fmt.Sprintf("Post #%d not found.", postID), false
+	}
+	selected, err := b.repo.ListTargets(ctx, postID)
 	if err != nil {
-		return err
+		slog.Error("List targets error", "err", err, "post_id", postID)
+		return "Error scheduling post. Please try again.", false
 	}
-	if selections["twitter"] {
-		if err := b.publishToTwitter(ctx, post); err != nil {
-			return err
-		}
+	hasTarget := false
+	for _, v := range selected {
+		hasTarget = hasTarget || v
 	}
-	if selections["pinterest"] {
-		if err := b.publishToPinterest(ctx, post); err != nil {
-			return err
-		}
+	if !hasTarget {
+		return "Select at least one platform first.", false
 	}
-	if selections["facebook"] {
-		if err := b.publishToFacebook(ctx, post); err != nil {
-			return err
+	if post.Status == "scheduled" {
+		// Rescheduling: the copies handed to the platforms carry the old time
+		if err := b.cancelNativeSchedules(ctx, postID); err != nil {
+			slog.Error("Cancel native schedule error", "err", err, "post_id", postID)
+			return fmt.Sprintf("Could not cancel the scheduled copy on %v. The schedule is unchanged.", err), false
 		}
 	}
-	if selections["instagram"] {
-		if err := b.publishToInstagram(ctx, post); err != nil {
-			return err
+	if err := b.repo.SchedulePost(ctx, postID, at); err != nil {
+		if errors.Is(err, storage.ErrNotSchedulable) {
+			return fmt.Sprintf("Post #%d is %s and cannot be scheduled.", postID, post.Status), false
 		}
+		slog.Error("Schedule post error", "err", err, "post_id", postID)
+		return "Error scheduling post. Please try again.", false
+	}
+	_ = b.repo.AddLog(ctx, postID, nil, "scheduled", at.UTC().Format(time.RFC3339))
+	reply := fmt.Sprintf("Post #%d scheduled for %s.", postID, b.formatTime(at))
+	if native := b.scheduleNatively(ctx, postID, at); len(native) > 0 {
+		reply += fmt.Sprintf(" It will also appear in the scheduled posts of: %s.", strings.Join(native, ", "))
 	}
-	return nil
+	return reply, true
 }
 
-func (b *Bot) publishToTwitter(ctx context.Context, p *storage.Post) error {
-	if b.config.TwitterConsumerKey == "" || b.config.TwitterConsumerSecret == "" || b.config.TwitterAccessToken == "" || b.config.TwitterAccessSecret == "" {
-		msg := "Twitter credentials missing"
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "twitter", "failed", nil, &msg)
-		_ = b.repo.AddLog(ctx, p.ID, ptr("twitter"), "error", msg)
-		return fmt.Errorf(msg)
-	}
-	twc, err := twitter.New(twitter.Credentials{
-		ConsumerKey:    b.config.TwitterConsumerKey,
-		ConsumerSecret: b.config.TwitterConsumerSecret,
-		AccessToken:    b.config.TwitterAccessToken,
-		AccessSecret:   b.config.TwitterAccessSecret,
-	})
-	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "twitter", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("twitter"), "error", err.Error())
-		return err
-	}
-
-	var media [][]byte
-	var mediaTypes []string
-	// Prefer multiple from post_media; fallback to single PhotoFileID
-	if items, err := b.repo.ListMedia(ctx, p.ID); err == nil && len(items) > 0 {
-		max := len(items)
-		if max > 4 {
-			max = 4
-		}
-		for i := 0; i < max; i++ {
-			if strings.ToLower(items[i].Type) != "photo" {
-				continue
-			}
-			data, ctype, err := b.downloadTelegramFile(ctx, items[i].FileID)
-			if err != nil {
-				continue
-			}
-			if ctype == "application/octet-stream" || ctype == "" {
-				ctype = "image/jpeg"
-			}
-			media = append(media, data)
-			mediaTypes = append(mediaTypes, ctype)
-		}
-	} else if p.PhotoFileID != nil {
-		data, ctype, err := b.downloadTelegramFile(ctx, *p.PhotoFileID)
-		if err == nil {
-			if ctype == "application/octet-stream" || ctype == "" {
-				ctype = "image/jpeg"
-			}
-			media = append(media, data)
-			mediaTypes = append(mediaTypes, ctype)
-		}
-	}
-	tweetID, err := twc.Publish(ctx, p.TextContent, media, mediaTypes)
-	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "twitter", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("twitter"), "error", err.Error())
-		return err
-	}
-	_ = b.repo.SetTargetStatus(ctx, p.ID, "twitter", "published", &tweetID, nil)
-	_ = b.repo.AddLog(ctx, p.ID, ptr("twitter"), "published", "tweet_id="+tweetID)
-	return nil
+// formatTime renders a time in the configured timezone.
+func (b *Bot) formatTime(t time.Time) string {
+	return t.In(b.config.Timezone).Format("2006-01-02 15:04 MST")
 }
 
-func (b *Bot) publishToPinterest(ctx context.Context, p *storage.Post) error {
-	if b.config.PinterestAccessToken == "" || b.config.PinterestBoardID == "" {
-		msg := "Pinterest token or board ID missing"
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "pinterest", "failed", nil, &msg)
-		_ = b.repo.AddLog(ctx, p.ID, ptr("pinterest"), "error", msg)
-		return fmt.Errorf(msg)
-	}
-	// Use first media if available, else single photo
-	var fileID string
-	if items, err := b.repo.ListMedia(ctx, p.ID); err == nil && len(items) > 0 {
-		for _, it := range items {
-			if strings.ToLower(it.Type) == "photo" {
-				fileID = it.FileID
-				break
-			}
-		}
-	} else {
-		if p.PhotoFileID != nil {
-			fileID = *p.PhotoFileID
-		}
-	}
-	if fileID == "" {
-		msg := "Pinterest requires an image"
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "pinterest", "failed", nil, &msg)
-		_ = b.repo.AddLog(ctx, p.ID, ptr("pinterest"), "error", msg)
-		return fmt.Errorf(msg)
+// platformLabels maps platform keys to the names shown to users
+var platformLabels = map[string]string{
+	"twitter":          "Twitter",
+	"pinterest":        "Pinterest",
+	"facebook":         "Facebook",
+	"instagram":        "Instagram",
+	"tiktok":           "TikTok",
+	"mastodon":         "Mastodon",
+	"bluesky":          "Bluesky",
+	"telegram_channel": "Telegram channel",
+	"linkedin":         "LinkedIn",
+	"threads":          "Threads",
+}
+
+func platformLabel(platform string) string {
+	if l, ok := platformLabels[platform]; ok {
+		return l
 	}
-	// Download image from Telegram
-	data, ctype, err := b.downloadTelegramFile(ctx, fileID)
+	return platform
+}
+
+// platformButtonsPerRow is the number of target toggles per keyboard row
+const platformButtonsPerRow = 3
+
+// buildTargetsMarkup builds inline keyboard with platform toggles and actions
+func (b *Bot) buildTargetsMarkup(ctx context.Context, postID int64) (tgbotapi.InlineKeyboardMarkup, error) {
+	// Caller provides context (usually from b.dbCtx)
+	selected, err := b.repo.ListTargets(ctx, postID)
 	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "pinterest", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("pinterest"), "error", "telegram download: "+err.Error())
-		return err
-	}
-	if ctype == "" {
-		ctype = "image/jpeg"
+		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
-	// Create client and pin
-	cli, err := pinterest.New(pinterest.Credentials{AccessToken: b.config.PinterestAccessToken})
+
+	post, err := b.repo.GetPost(ctx, postID)
 	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "pinterest", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("pinterest"), "error", err.Error())
-		return err
-	}
-	// Pinterest recommends short title; use truncated text if present
-	title := p.TextContent
-	if len(title) > 100 {
-		title = title[:100]
+		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
-	pinID, err := cli.CreatePin(ctx, b.config.PinterestBoardID, title, p.TextContent, "", data, ctype)
+
+	rows, err := b.targetRows(ctx, post, selected, func(key string) string { return fmt.Sprintf("tgl:%d:%s", postID, key) })
 	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "pinterest", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("pinterest"), "error", err.Error())
-		return err
+		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
-	_ = b.repo.SetTargetStatus(ctx, p.ID, "pinterest", "published", &pinID, nil)
-	_ = b.repo.AddLog(ctx, p.ID, ptr("pinterest"), "published", "pin_id="+pinID)
-	return nil
+	if row := pinterestBoardRow(post, selected); row != nil {
+		rows = append(rows, row)
+	}
+	actions := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🚀 Publish", fmt.Sprintf("pub:%d", postID)),
+		tgbotapi.NewInlineKeyboardButtonData("🗓 Schedule", fmt.Sprintf("sch:%d", postID)),
+		tgbotapi.NewInlineKeyboardButtonData("✖️ Cancel", fmt.Sprintf("can:%d", postID)),
+	)
+
+	return tgbotapi.NewInlineKeyboardMarkup(append(rows, actions)...), nil
 }
 
-func (b *Bot) publishToFacebook(ctx context.Context, p *storage.Post) error {
-	if b.config.FacebookAccessToken == "" || b.config.FacebookPageID == "" {
-		msg := "Facebook access token or page ID missing"
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "facebook", "failed", nil, &msg)
-		_ = b.repo.AddLog(ctx, p.ID, ptr("facebook"), "error", msg)
-		return fmt.Errorf(msg)
+// buildSetupTargetsMarkup is like buildTargetsMarkup but uses ps:toggle callbacks and appends Next/Cancel row.
+func (b *Bot) buildSetupTargetsMarkup(ctx context.Context, postID int64) (tgbotapi.InlineKeyboardMarkup, error) {
+	selected, err := b.repo.ListTargets(ctx, postID)
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
-	cli, err := facebook.New(facebook.Credentials{AccessToken: b.config.FacebookAccessToken})
+	post, err := b.repo.GetPost(ctx, postID)
 	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "facebook", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("facebook"), "error", err.Error())
-		return err
-	}
-	var img []byte
-	var ctype string
-	if items, err := b.repo.ListMedia(ctx, p.ID); err == nil && len(items) > 0 {
-		var fid string
-		for _, it := range items {
-			if strings.ToLower(it.Type) == "photo" {
-				fid = it.FileID
-				break
-			}
-		}
-		if fid != "" {
-			img, ctype, err = b.downloadTelegramFile(ctx, fid)
-			if err != nil {
-				img = nil
-			}
-		}
-	} else if p.PhotoFileID != nil {
-		img, ctype, err = b.downloadTelegramFile(ctx, *p.PhotoFileID)
-		if err != nil {
-			_ = b.repo.SetTargetStatus(ctx, p.ID, "facebook", "failed", nil, strptr(err.Error()))
-			_ = b.repo.AddLog(ctx, p.ID, ptr("facebook"), "error", "telegram download: "+err.Error())
-			return err
-		}
+		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
-	id, err := cli.CreatePost(ctx, b.config.FacebookPageID, p.TextContent, img, ctype)
+	rows, err := b.targetRows(ctx, post, selected, func(key string) string { return fmt.Sprintf("ps:toggle:%d:%s", postID, key) })
 	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "facebook", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("facebook"), "error", err.Error())
-		return err
+		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
-	_ = b.repo.SetTargetStatus(ctx, p.ID, "facebook", "published", &id, nil)
-	_ = b.repo.AddLog(ctx, p.ID, ptr("facebook"), "published", "id="+id)
-	return nil
+	next := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Next ▶️ Media", fmt.Sprintf("ps:media:%d", postID)),
+		tgbotapi.NewInlineKeyboardButtonData("Cancel", fmt.Sprintf("ps:cancel:%d", postID)),
+	)
+	return tgbotapi.NewInlineKeyboardMarkup(append(rows, next)...), nil
 }
 
-func (b *Bot) publishToInstagram(ctx context.Context, p *storage.Post) error {
-	if b.config.InstagramAccessToken == "" || b.config.InstagramUserID == "" {
-		msg := "Instagram access token or user ID missing"
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "instagram", "failed", nil, &msg)
-		_ = b.repo.AddLog(ctx, p.ID, ptr("instagram"), "error", msg)
-		return fmt.Errorf(msg)
-	}
-	// Prefer first media if present
-	var fileID string
-	if items, err := b.repo.ListMedia(ctx, p.ID); err == nil && len(items) > 0 {
-		for _, it := range items {
-			if strings.ToLower(it.Type) == "photo" {
-				fileID = it.FileID
-				break
-			}
-		}
-	} else {
-		if p.PhotoFileID != nil {
-			fileID = *p.PhotoFileID
-		}
-	}
-	if fileID == "" {
-		msg := "Instagram requires an image"
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "instagram", "failed", nil, &msg)
-		_ = b.repo.AddLog(ctx, p.ID, ptr("instagram"), "error", msg)
-		return fmt.Errorf(msg)
-	}
-	imgURL, err := b.getTelegramFileURL(fileID)
+// buildConfirmTargetsMarkup shows toggles and Confirm/Cancel.
+func (b *Bot) buildConfirmTargetsMarkup(ctx context.Context, postID int64) (tgbotapi.InlineKeyboardMarkup, error) {
+	selected, err := b.repo.ListTargets(ctx, postID)
 	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "instagram", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("instagram"), "error", "telegram get url: "+err.Error())
-		return err
+		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
-	cli, err := instagram.New(instagram.Credentials{AccessToken: b.config.InstagramAccessToken})
+	post, err := b.repo.GetPost(ctx, postID)
 	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "instagram", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("instagram"), "error", err.Error())
-		return err
+		return tgbotapi.InlineKeyboardMarkup{}, err
 	}
-	id, err := cli.CreatePhotoPost(ctx, b.config.InstagramUserID, p.TextContent, imgURL)
+	rows, err := b.targetRows(ctx, post, selected, func(key string) string { return fmt.Sprintf("ps:toggle:%d:%s", postID, key) })
 	if err != nil {
-		_ = b.repo.SetTargetStatus(ctx, p.ID, "instagram", "failed", nil, strptr(err.Error()))
-		_ = b.repo.AddLog(ctx, p.ID, ptr("instagram"), "error", err.Error())
-		return err
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	if row := pinterestBoardRow(post, selected); row != nil {
+		rows = append(rows, row)
 	}
-	_ = b.repo.SetTargetStatus(ctx, p.ID, "instagram", "published", &id, nil)
-	_ = b.repo.AddLog(ctx, p.ID, ptr("instagram"), "published", "id="+id)
-	return nil
+	actions := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Confirm ✅", fmt.Sprintf("ps:confirm:%d", postID)),
+		tgbotapi.NewInlineKeyboardButtonData("Cancel", fmt.Sprintf("ps:cancel:%d", postID)),
+	)
+	return tgbotapi.NewInlineKeyboardMarkup(append(rows, actions)...), nil
 }
 
 func ptr[T any](v T) *T       { return &v }
@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"context"
+	"sync"
+
+	"trinity_bot/internal/connectors"
+)
+
+// mediaCache is the connectors.MediaSource used for a publish run. It downloads each Telegram file
+// at most once, so platforms publishing the same post concurrently share the bytes.
+type mediaCache struct {
+	b     *Bot
+	mu    sync.Mutex
+	files map[string]*cachedFile
+}
+
+type cachedFile struct {
+	done  chan struct{} // closed once the download finished
+	data  []byte
+	ctype string
+	err   error
+}
+
+func (b *Bot) newMediaCache() *mediaCache {
+	return &mediaCache{b: b, files: make(map[string]*cachedFile)}
+}
+
+// Fetch returns the file's bytes and content type. Concurrent callers for the same file wait for
+// a single download; failed downloads are not cached so a later caller can try again.
+func (m *mediaCache) Fetch(ctx context.Context, fileID string) ([]byte, string, error) {
+	m.mu.Lock()
+	f, ok := m.files[fileID]
+	if !ok {
+		f = &cachedFile{done: make(chan struct{})}
+		m.files[fileID] = f
+		m.mu.Unlock()
+
+		f.data, f.ctype, f.err = m.b.downloadTelegramFile(ctx, fileID)
+		if f.err != nil {
+			m.mu.Lock()
+			delete(m.files, fileID)
+			m.mu.Unlock()
+		}
+		close(f.done)
+	} else {
+		m.mu.Unlock()
+	}
+
+	select {
+	case <-f.done:
+		return f.data, f.ctype, f.err
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+}
+
+// URL returns a short-lived signed URL of the bot's media endpoint for the file. Telegram's own
+// file URLs embed the bot token and are never handed out.
+func (m *mediaCache) URL(ctx context.Context, fileID string) (string, error) {
+	if m.b.media == nil {
+		return "", connectors.ValidationError("", "publishing media by URL requires MEDIA_BASE_URL or PUBLIC_BASE_URL")
+	}
+	return m.b.media.URL(ctx, fileID, m.Fetch)
+}
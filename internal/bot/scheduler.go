@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"trinity_bot/internal/connectors"
+	"trinity_bot/internal/storage"
+	"trinity_bot/pkg/utils"
+)
+
+const schedulerInterval = 30 * time.Second
+
+// StartScheduler launches the loop that hands due scheduled posts over to the publish worker.
+// It runs until Stop is called.
+func (b *Bot) StartScheduler() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.runScheduler()
+	}()
+}
+
+func (b *Bot) runScheduler() {
+	slog.Info("Starting post scheduler")
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for {
+		b.releaseDuePosts()
+		select {
+		case <-b.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseDuePosts queues scheduled posts whose time has come and wakes the worker.
+func (b *Bot) releaseDuePosts() {
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+	posts, err := b.repo.ReleaseDuePosts(ctx)
+	if err != nil {
+		slog.Error("Release scheduled posts error", "err", err)
+		return
+	}
+	if len(posts) == 0 {
+		return
+	}
+	b.notifyWorker()
+	for _, p := range posts {
+		slog.Info("Scheduled post released", "post_id", p.ID)
+		_ = b.repo.AddLog(ctx, p.ID, nil, "schedule_released", "")
+		if _, err := b.api.Send(tgbotapi.NewMessage(p.ChatID, fmt.Sprintf("Scheduled post #%d is being published.", p.ID))); err != nil {
+			slog.Error("Send schedule release message error", "err", err, "post_id", p.ID)
+		}
+		// Posts whose targets were all scheduled natively leave the worker nothing to do
+		b.finishPost(ctx, &p)
+	}
+}
+
+// nativeScheduler returns the target's publisher if its platform can schedule a post for at itself.
+func (b *Bot) nativeScheduler(ctx context.Context, t storage.Target, at time.Time) (connectors.NativeScheduler, bool) {
+	pub, err := b.publisher(ctx, t)
+	if err != nil {
+		return nil, false
+	}
+	ns, ok := pub.(connectors.NativeScheduler)
+	if !ok || !ns.CanSchedule(at) {
+		return nil, false
+	}
+	return ns, true
+}
+
+// scheduleNatively queues the selected targets whose platforms can schedule the post themselves,
+// so the worker hands them over right away and the post shows up in the platform's own planner.
+// Returns the labels of those targets.
+func (b *Bot) scheduleNatively(ctx context.Context, postID int64, at time.Time) []string {
+	targets, err := b.repo.ListPostTargets(ctx, postID)
+	if err != nil {
+		slog.Error("List post targets error", "err", err, "post_id", postID)
+		return nil
+	}
+	names := b.accountNames(ctx)
+	var native []string
+	for _, t := range targets {
+		if t.Status != "pending" && t.Status != "failed" {
+			continue
+		}
+		if _, ok := b.nativeScheduler(ctx, t, at); !ok {
+			continue
+		}
+		if err := b.repo.QueueTarget(ctx, t.ID); err != nil {
+			slog.Error("Queue native schedule error", "err", err, "post_id", postID, "platform", t.Platform)
+			continue
+		}
+		native = append(native, targetLabel(t, names))
+	}
+	if len(native) > 0 {
+		b.notifyWorker()
+	}
+	return native
+}
+
+// cancelNativeSchedules deletes the natively scheduled copies of a post on their platforms and
+// turns those targets back into pending ones. It stops at the first platform that fails; a target
+// deleted only in part keeps the IDs still scheduled, so a later cancel deletes just those.
+func (b *Bot) cancelNativeSchedules(ctx context.Context, postID int64) error {
+	targets, err := b.repo.ListPostTargets(ctx, postID)
+	if err != nil {
+		return err
+	}
+	for _, t := range targets {
+		if t.Status != "scheduled" || t.ExternalPostID == nil {
+			continue
+		}
+		pub, err := b.publisher(ctx, t)
+		if err != nil {
+			return err
+		}
+		ns, ok := pub.(connectors.NativeScheduler)
+		if !ok {
+			return fmt.Errorf("%s cannot unschedule posts", platformLabel(t.Platform))
+		}
+		if err := ns.Unschedule(ctx, *t.ExternalPostID); err != nil {
+			var ue *connectors.UnscheduleError
+			if errors.As(err, &ue) {
+				if serr := b.repo.SetTargetStatus(ctx, t.ID, "scheduled", &ue.Remaining, nil); serr != nil {
+					slog.Error("Record partial unschedule error", "err", serr, "post_id", postID, "platform", t.Platform, "remaining", ue.Remaining)
+				}
+				_ = b.repo.AddLog(ctx, postID, ptr(t.Platform), "unscheduled_partially", "remaining="+ue.Remaining)
+			}
+			return fmt.Errorf("%s: %w", platformLabel(t.Platform), err)
+		}
+		if err := b.repo.SetTargetStatus(ctx, t.ID, "pending", nil, nil); err != nil {
+			return err
+		}
+		_ = b.repo.AddLog(ctx, postID, ptr(t.Platform), "unscheduled_natively", "id="+*t.ExternalPostID)
+	}
+	return nil
+}
+
+// reportNativeSchedule tells the chat how handing a scheduled post to the platforms went. Failed
+// targets are published by the bot itself at the scheduled time. If the post was unscheduled in
+// the meantime, the copies just scheduled are deleted again.
+func (b *Bot) reportNativeSchedule(ctx context.Context, p *storage.Post, claimed []storage.Target) {
+	if cur, err := b.repo.GetPost(ctx, p.ID); err == nil && cur.Status != "scheduled" {
+		if err := b.cancelNativeSchedules(ctx, p.ID); err != nil {
+			slog.Error("Cancel native schedule error", "err", err, "post_id", p.ID)
+		}
+		return
+	}
+	targets, err := b.repo.ListPostTargets(ctx, p.ID)
+	if err != nil {
+		slog.Error("List post targets error", "err", err, "post_id", p.ID)
+		return
+	}
+	wasClaimed := make(map[int64]bool, len(claimed))
+	for _, t := range claimed {
+		wasClaimed[t.ID] = true
+	}
+	names := b.accountNames(ctx)
+	var lines []string
+	for _, t := range targets {
+		if !wasClaimed[t.ID] {
+			continue
+		}
+		switch t.Status {
+		case "scheduled":
+			lines = append(lines, fmt.Sprintf("✅ %s: scheduled in the %s planner (id %s)", targetLabel(t, names), platformLabel(t.Platform), *t.ExternalPostID))
+		case "failed":
+			reason := "unknown error"
+			if t.Error != nil && *t.Error != "" {
+				reason = utils.TruncateText(*t.Error, 200)
+			}
+			lines = append(lines, fmt.Sprintf("❌ %s: %s. The bot will publish it itself at the scheduled time.", targetLabel(t, names), reason))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	msg := fmt.Sprintf("Post #%d:\n%s", p.ID, strings.Join(lines, "\n"))
+	if _, err := b.api.Send(tgbotapi.NewMessage(p.ChatID, msg)); err != nil {
+		slog.Error("Send native schedule result error", "err", err, "post_id", p.ID)
+	}
+}
@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"trinity_bot/internal/storage"
+)
+
+const (
+	tokenCheckInterval = 30 * time.Minute
+	// tokenRefreshMargin is how long before expiry tokens are refreshed. It is well above
+	// tokenCheckInterval so that short-lived tokens (Twitter's last two hours) never lapse.
+	tokenRefreshMargin = 24 * time.Hour
+	// tokenRefreshTimeout bounds one account's refresh.
+	tokenRefreshTimeout = 30 * time.Second
+	// tokenStoreAttempts is how often storing a refreshed token is tried, each with a fresh context.
+	tokenStoreAttempts = 3
+)
+
+// errTokenLost is returned when a refreshed token could not be stored after the platform rotated
+// the refresh token: the stored one is no longer valid, so the account must be connected again.
+var errTokenLost = errors.New("refreshed token could not be stored")
+
+// StartTokenMonitor launches the loop that refreshes the tokens of stored accounts before they
+// expire and warns the owners of tokens that cannot be refreshed. It runs until Stop is called.
+func (b *Bot) StartTokenMonitor() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.runTokenMonitor()
+	}()
+}
+
+func (b *Bot) runTokenMonitor() {
+	slog.Info("Starting token monitor")
+	ticker := time.NewTicker(tokenCheckInterval)
+	defer ticker.Stop()
+	for {
+		b.checkTokens()
+		select {
+		case <-b.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkTokens refreshes the tokens due for a refresh and warns about expiring tokens that cannot be
+// refreshed (or whose refresh failed). Each owner is warned once per token.
+func (b *Bot) checkTokens() {
+	ctx, cancel := b.dbCtx()
+	accounts, err := b.accounts.ListExpiringAccounts(ctx, time.Now().Add(max(tokenRefreshMargin, b.config.TokenExpiryWarning)))
+	cancel()
+	if err != nil {
+		slog.Error("List expiring accounts error", "err", err)
+		return
+	}
+	for _, a := range accounts {
+		left := time.Until(*a.TokenExpiresAt)
+		refreshable := b.oauth != nil && b.oauth.CanRefresh(&a)
+		var refreshErr error
+		if refreshable && left < tokenRefreshMargin {
+			if refreshErr = b.refreshToken(&a); refreshErr == nil || errors.Is(refreshErr, errTokenLost) {
+				continue
+			}
+		}
+		if (refreshable && refreshErr == nil) || left >= b.config.TokenExpiryWarning || a.ExpiryWarnedAt != nil {
+			continue
+		}
+		b.warnTokenExpiry(&a, refreshErr)
+	}
+}
+
+// refreshToken renews an account's token and stores the new tokens. Platforms such as Twitter
+// rotate refresh tokens, so once a refresh succeeded the old refresh token is gone: storing is
+// retried, and if it still fails the owner is told to connect the account again (errTokenLost).
+func (b *Bot) refreshToken(a *storage.Account) error {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
+	tok, err := b.oauth.Refresh(ctx, a)
+	cancel()
+	if err != nil {
+		slog.Error("Token refresh error", "err", err, "account_id", a.ID, "platform", a.Platform)
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := b.dbCtx()
+		err = b.accounts.UpdateAccountTokens(ctx, a.ID, tok.AccessToken, tok.RefreshToken, tok.ExpiresAt)
+		cancel()
+		if err == nil {
+			slog.Info("Token refreshed", "account_id", a.ID, "platform", a.Platform, "expires_at", tok.ExpiresAt)
+			return nil
+		}
+		if attempt == tokenStoreAttempts {
+			break
+		}
+		slog.Warn("Store refreshed token error, retrying", "err", err, "account_id", a.ID, "platform", a.Platform, "attempt", attempt)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	if tok.RefreshToken == a.RefreshToken {
+		// The stored refresh token is still valid; the next check refreshes again
+		slog.Error("Store refreshed token error", "err", err, "account_id", a.ID, "platform", a.Platform)
+		return err
+	}
+	slog.Error("Refreshed token lost: the platform rotated the refresh token and storing the new one failed; the account must be connected again",
+		"err", err, "account_id", a.ID, "platform", a.Platform, "user_id", a.OwnerUserID)
+	b.warnTokenLost(a)
+	return fmt.Errorf("%w: %w", errTokenLost, err)
+}
+
+// warnTokenLost tells the account's owner that its token was renewed but could not be saved.
+func (b *Bot) warnTokenLost(a *storage.Account) {
+	if a.OwnerUserID == 0 {
+		return
+	}
+	text := fmt.Sprintf("⚠️ The token of %s · %s (account #%d) was renewed, but saving it failed and the old one is no longer valid. Posts to it fail until it is connected again",
+		platformLabel(a.Platform), a.Name, a.ID)
+	if b.oauth != nil && b.oauth.Has(a.Platform) && b.config.PublicBaseURL != "" {
+		text += fmt.Sprintf(" with /connect %s.", a.Platform)
+	} else {
+		text += "."
+	}
+	if _, err := b.SendMessage(a.OwnerUserID, text); err != nil {
+		slog.Error("Send token lost warning error", "err", err, "account_id", a.ID)
+	}
+}
+
+// warnTokenExpiry tells the account's owner in a private chat that its token expires (or expired)
+// and how to renew it.
+func (b *Bot) warnTokenExpiry(a *storage.Account, refreshErr error) {
+	if a.OwnerUserID == 0 {
+		slog.Warn("Token expires and the account has no owner to warn", "account_id", a.ID, "platform", a.Platform, "expires_at", a.TokenExpiresAt)
+		return
+	}
+	label := fmt.Sprintf("%s · %s (account #%d)", platformLabel(a.Platform), a.Name, a.ID)
+	var text string
+	if time.Now().After(*a.TokenExpiresAt) {
+		text = fmt.Sprintf("⚠️ The token of %s expired on %s. Posts to it fail until it is renewed.", label, b.formatTime(*a.TokenExpiresAt))
+	} else {
+		text = fmt.Sprintf("⚠️ The token of %s expires on %s.", label, b.formatTime(*a.TokenExpiresAt))
+	}
+	if refreshErr != nil {
+		// The error may quote the provider's response or request; it is logged, not sent
+		slog.Warn("Token refresh failed before expiry warning", "err", refreshErr, "account_id", a.ID, "platform", a.Platform)
+		text += " Refreshing it automatically failed."
+	}
+	if b.oauth != nil && b.oauth.Has(a.Platform) && b.config.PublicBaseURL != "" {
+		text += fmt.Sprintf(" Run /connect %s to renew it.", a.Platform)
+	} else {
+		text += " Replace its token to keep publishing."
+	}
+	if _, err := b.SendMessage(a.OwnerUserID, text); err != nil {
+		slog.Error("Send token expiry warning error", "err", err, "account_id", a.ID)
+		return
+	}
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+	if err := b.accounts.MarkExpiryWarned(ctx, a.ID); err != nil {
+		slog.Error("Mark expiry warned error", "err", err, "account_id", a.ID)
+	}
+	slog.Info("Token expiry warning sent", "account_id", a.ID, "platform", a.Platform, "user_id", a.OwnerUserID)
+}
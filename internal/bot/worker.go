@@ -0,0 +1,369 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+	"trinity_bot/internal/storage"
+	"trinity_bot/pkg/utils"
+)
+
+const (
+	workerPollInterval = 2 * time.Second
+	workerBatchSize    = 10
+	// targetLease must comfortably exceed publishTimeout; a target claimed longer ago than this
+	// is assumed to belong to a dead worker and is claimed again.
+	targetLease = 15 * time.Minute
+	// publishTimeout bounds one post's whole fan-out; per-platform timeouts come from config.
+	publishTimeout = config.PostPublishTimeout
+)
+
+// StartWorker launches the background publish worker. It drains queued post_targets rows
+// until Stop is called; queued work is persisted in the DB and resumed after a restart.
+func (b *Bot) StartWorker() {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.runWorker()
+	}()
+}
+
+// notifyWorker wakes the worker up without waiting for the next poll tick.
+func (b *Bot) notifyWorker() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *Bot) runWorker() {
+	slog.Info("Starting publish worker")
+	ticker := time.NewTicker(workerPollInterval)
+	defer ticker.Stop()
+	for {
+		// Keep claiming while full batches come back, then wait for a tick or a wake-up
+		for b.processQueue() == workerBatchSize {
+			select {
+			case <-b.stopChan:
+				return
+			default:
+			}
+		}
+		select {
+		case <-b.stopChan:
+			return
+		case <-ticker.C:
+		case <-b.wake:
+		}
+	}
+}
+
+// processQueue claims one batch of queued targets and publishes them, posts in parallel.
+// Returns the batch size.
+func (b *Bot) processQueue() int {
+	ctx, cancel := b.dbCtx()
+	targets, err := b.repo.ClaimTargets(ctx, workerBatchSize, targetLease)
+	cancel()
+	if err != nil {
+		slog.Error("Claim targets error", "err", err)
+		return 0
+	}
+
+	// Group by post to load each post once and report its outcome after all its targets ran
+	var order []int64
+	byPost := make(map[int64][]storage.Target)
+	for _, t := range targets {
+		if _, ok := byPost[t.PostID]; !ok {
+			order = append(order, t.PostID)
+		}
+		byPost[t.PostID] = append(byPost[t.PostID], t)
+	}
+	var wg sync.WaitGroup
+	for _, postID := range order {
+		wg.Add(1)
+		go func(postID int64, targets []storage.Target) {
+			defer wg.Done()
+			b.publishSelected(postID, targets)
+		}(postID, byPost[postID])
+	}
+	wg.Wait()
+	return len(targets)
+}
+
+// targetResult is the outcome of one target's publish attempt.
+type targetResult struct {
+	target     storage.Target
+	externalID string
+	err        error
+}
+
+// publishSelected fans the claimed targets of one post out to their platforms in parallel.
+// All attempts share one parent context (and one media cache); each platform gets its own timeout,
+// and b.publishSem bounds how many uploads run at once across all posts. Results are recorded
+// on post_targets once every attempt returned. Stop cancels the parent context; interrupted
+// targets go back to the queue.
+func (b *Bot) publishSelected(postID int64, targets []storage.Target) {
+	ctx, cancel := context.WithTimeout(b.publishCtx, publishTimeout)
+	defer cancel()
+
+	post, err := b.repo.GetPost(ctx, postID)
+	var cp *connectors.Post
+	if err == nil {
+		cp, err = b.connectorPost(ctx, post)
+	}
+	if err == nil {
+		b.fanOut(ctx, cp, targets)
+	} else {
+		slog.Error("Load queued post error", "err", err, "post_id", postID)
+		for _, t := range targets {
+			b.recordResult(&connectors.Post{ID: postID}, targetResult{target: t, err: err})
+		}
+	}
+	if b.publishCtx.Err() != nil {
+		return // stopping: the post is reported once its requeued targets ran
+	}
+
+	// The post's context may have run out; reporting gets its own
+	rctx, rcancel := b.apiCtx()
+	defer rcancel()
+	if post == nil {
+		if post, err = b.repo.GetPost(rctx, postID); err != nil {
+			slog.Error("Load post for report error", "err", err, "post_id", postID)
+			return
+		}
+	}
+	if cp != nil && cp.ScheduledAt != nil {
+		b.reportNativeSchedule(rctx, post, targets)
+		return
+	}
+	b.finishPost(rctx, post)
+}
+
+// connectorPost converts a stored post into the normalized form publishers take. Posts created
+// from a single photo message have no post_media rows; their photo becomes the only attachment.
+// Targets of a still scheduled post are being handed to native scheduling, so the post carries
+// its scheduled time.
+func (b *Bot) connectorPost(ctx context.Context, p *storage.Post) (*connectors.Post, error) {
+	items, err := b.repo.ListMedia(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	cp := &connectors.Post{
+		ID:                 p.ID,
+		Text:               p.TextContent,
+		Files:              b.newMediaCache(),
+		Link:               p.Link,
+		PinterestBoardID:   p.PinterestBoardID,
+		PinterestSectionID: p.PinterestSectionID,
+	}
+	if p.Status == "scheduled" {
+		cp.ScheduledAt = p.ScheduledAt
+	}
+	for _, it := range items {
+		cp.Media = append(cp.Media, connectors.Media{FileID: it.FileID, Type: strings.ToLower(it.Type), Description: it.Description})
+	}
+	if len(cp.Media) == 0 && p.PhotoFileID != nil {
+		cp.Media = append(cp.Media, connectors.Media{FileID: *p.PhotoFileID, Type: "photo"})
+	}
+	return cp, nil
+}
+
+// fanOut publishes the post to every target concurrently; a failure on one platform never
+// stops the others.
+func (b *Bot) fanOut(ctx context.Context, p *connectors.Post, targets []storage.Target) {
+	results := make([]targetResult, len(targets)) // each goroutine writes only its own slot
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t storage.Target) {
+			defer wg.Done()
+			results[i] = targetResult{target: t}
+			select {
+			case b.publishSem <- struct{}{}:
+				defer func() { <-b.publishSem }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+			tctx, tcancel := context.WithTimeout(ctx, b.config.PublishTimeoutFor(t.Platform))
+			defer tcancel()
+			b.addLog(p.ID, t.Platform, "attempt", fmt.Sprintf("attempt=%d", t.Attempts))
+			results[i].externalID, results[i].err = b.publishTarget(tctx, p, t)
+		}(i, t)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		b.recordResult(p, r)
+	}
+}
+
+// recordResult stores one target's outcome. It runs on a fresh DB context: the post's context
+// may have run out by now, and a lost write would leave the target publishing until its lease
+// expires and it is published again.
+func (b *Bot) recordResult(p *connectors.Post, r targetResult) {
+	t := r.target
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+	if r.err != nil && errors.Is(r.err, context.Canceled) && b.publishCtx.Err() != nil {
+		// Interrupted by Stop: claim it again after the restart
+		slog.Warn("Publish interrupted by shutdown", "post_id", p.ID, "platform", t.Platform, "account_id", t.AccountID)
+		if err := b.repo.RetryTarget(ctx, t.ID, time.Now(), "interrupted by shutdown"); err != nil {
+			slog.Error("Requeue interrupted target error", "err", err, "post_id", p.ID, "platform", t.Platform)
+		}
+		return
+	}
+	if r.err == nil {
+		status, event := "published", "published"
+		if p.ScheduledAt != nil {
+			status, event = "scheduled", "scheduled_natively"
+		}
+		if err := b.repo.SetTargetStatus(ctx, t.ID, status, &r.externalID, nil); err != nil {
+			slog.Error("Record publish result error", "err", err, "post_id", p.ID, "platform", t.Platform, "external_id", r.externalID)
+		}
+		b.addLog(p.ID, t.Platform, event, "id="+r.externalID)
+		return
+	}
+	slog.Error("Publish target error", "err", r.err, "post_id", p.ID, "platform", t.Platform, "account_id", t.AccountID, "attempt", t.Attempts, "kind", connectors.KindOf(r.err).String())
+	b.addLog(p.ID, t.Platform, "error", r.err.Error())
+	if !b.scheduleRetry(ctx, p.ID, t, r.err) {
+		if err := b.repo.SetTargetStatus(ctx, t.ID, "failed", nil, strptr(r.err.Error())); err != nil {
+			slog.Error("Record publish result error", "err", err, "post_id", p.ID, "platform", t.Platform)
+		}
+	}
+}
+
+// addLog writes a post_logs entry for a platform on its own DB context, logging failures.
+func (b *Bot) addLog(postID int64, platform, event, details string) {
+	ctx, cancel := b.dbCtx()
+	defer cancel()
+	if err := b.repo.AddLog(ctx, postID, ptr(platform), event, details); err != nil {
+		slog.Error("Add post log error", "err", err, "post_id", postID, "platform", platform, "event", event)
+	}
+}
+
+// publishTarget publishes a post to a single target through its platform's registered connector and
+// returns the platform's ID for it. A connector panic is turned into an error.
+func (b *Bot) publishTarget(ctx context.Context, p *connectors.Post, t storage.Target) (externalID string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Publish target panic", "panic", r, "post_id", p.ID, "platform", t.Platform)
+			err = fmt.Errorf("%s connector panic: %v", t.Platform, r)
+		}
+	}()
+	pub, err := b.publisher(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	return pub.Publish(ctx, p)
+}
+
+// scheduleRetry puts a failed target back into the queue with backoff when the failure is
+// transient and the platform's attempt cap is not reached yet. Returns false if it was not retried.
+func (b *Bot) scheduleRetry(ctx context.Context, postID int64, t storage.Target, err error) bool {
+	maxAttempts := b.config.MaxAttempts(t.Platform)
+	if !connectors.Retryable(err) || t.Attempts >= maxAttempts {
+		return false
+	}
+	delay := backoff(t.Attempts, b.config.RetryBaseDelay, b.config.RetryMaxDelay)
+	if ra := connectors.RetryAfter(err); ra > delay {
+		delay = ra
+	}
+	next := time.Now().Add(delay)
+	if err := b.repo.RetryTarget(ctx, t.ID, next, err.Error()); err != nil {
+		slog.Error("Schedule retry error", "err", err, "post_id", postID, "platform", t.Platform)
+		return false
+	}
+	b.addLog(postID, t.Platform, "retry_scheduled",
+		fmt.Sprintf("attempt=%d/%d kind=%s next_retry_at=%s", t.Attempts, maxAttempts, connectors.KindOf(err), next.UTC().Format(time.RFC3339)))
+	return true
+}
+
+// backoff returns the delay after the given (1-based) attempt: base doubled per attempt and capped
+// at maxDelay, with the upper half jittered so retries of many targets do not fire in lockstep.
+func backoff(attempt int, base, maxDelay time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < maxDelay; i++ {
+		d *= 2
+	}
+	if d > maxDelay {
+		d = maxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// finishPost derives the post's final status from its targets once all of them are done
+// and reports a per-platform summary to the chat.
+func (b *Bot) finishPost(ctx context.Context, p *storage.Post) {
+	targets, err := b.repo.ListPostTargets(ctx, p.ID)
+	if err != nil {
+		slog.Error("List post targets error", "err", err, "post_id", p.ID)
+		return
+	}
+	status := postStatus(targets)
+	done, err := b.repo.FinishPost(ctx, p.ID, status)
+	if err != nil {
+		slog.Error("Finish post error", "err", err, "post_id", p.ID)
+		return
+	}
+	if !done {
+		// Other targets are still in flight or another worker already reported the post
+		return
+	}
+	_ = b.repo.AddLog(ctx, p.ID, nil, status, "")
+	if _, err := b.api.Send(tgbotapi.NewMessage(p.ChatID, publishSummary(p.ID, status, targets, b.accountNames(ctx)))); err != nil {
+		slog.Error("Send publish result error", "err", err, "post_id", p.ID)
+	}
+}
+
+// postStatus derives a post's overall status from its finished targets:
+// published (all succeeded), partially_published (some succeeded) or failed (none succeeded).
+func postStatus(targets []storage.Target) string {
+	published := 0
+	for _, t := range targets {
+		if t.Status == "published" {
+			published++
+		}
+	}
+	switch {
+	case published == 0:
+		return "failed"
+	case published < len(targets):
+		return "partially_published"
+	default:
+		return "published"
+	}
+}
+
+// publishSummary renders one line per target with its outcome; names labels account targets.
+func publishSummary(postID int64, status string, targets []storage.Target, names map[int64]string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Post #%d: %s", postID, strings.ReplaceAll(status, "_", " "))
+	for _, t := range targets {
+		sb.WriteString("\n")
+		if t.Status == "published" {
+			fmt.Fprintf(&sb, "✅ %s: published", targetLabel(t, names))
+			if t.ExternalPostID != nil && *t.ExternalPostID != "" {
+				fmt.Fprintf(&sb, " (id %s)", *t.ExternalPostID)
+			}
+			continue
+		}
+		reason := "unknown error"
+		if t.Error != nil && *t.Error != "" {
+			reason = utils.TruncateText(*t.Error, 200)
+		}
+		fmt.Fprintf(&sb, "❌ %s: %s", targetLabel(t, names), reason)
+	}
+	return sb.String()
+}
@@ -2,9 +2,16 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"trinity_bot/internal/secrets"
 )
 
 // Config stores the application configuration
@@ -12,9 +19,12 @@ type Config struct {
 	TelegramToken string
 	DebugMode     bool
 	UpdateTimeout int
-	AllowedUsers  []int64 // Optional: List of allowed user IDs
-	WebhookURL    string  // Optional: for webhook mode instead of polling
-	WebhookPort   string  // Optional: port for webhook server
+	AllowedUsers  []int64        // Optional: List of allowed user IDs
+	AccountAdmins []int64        // Optional: users who see and manage every stored account, not just their own
+	WebhookURL    string         // Optional: for webhook mode instead of polling
+	WebhookPort   string         // Optional: port of the HTTP server (webhook, OAuth callbacks)
+	PublicBaseURL string         // Optional: public URL of the HTTP server, enables /connect
+	Timezone      *time.Location // Used to interpret /schedule times (default UTC)
 
 	// Database configuration
 	DatabaseURL string // Prefer single DATABASE_URL
@@ -24,11 +34,45 @@ type Config struct {
 	DBPassword  string
 	DBName      string
 
+	// Publish retries: transient failures are retried with jittered exponential backoff
+	RetryMaxAttempts         int            // attempts per target, including the first (PUBLISH_MAX_ATTEMPTS)
+	RetryPlatformMaxAttempts map[string]int // per-platform caps (PUBLISH_MAX_ATTEMPTS_BY_PLATFORM=twitter=3,instagram=5)
+	RetryBaseDelay           time.Duration  // delay before the first retry (PUBLISH_RETRY_BASE_DELAY)
+	RetryMaxDelay            time.Duration  // upper bound for a single backoff step (PUBLISH_RETRY_MAX_DELAY)
+
+	// Publish fan-out: platforms of a post are published in parallel
+	PublishConcurrency      int                      // max concurrent platform publishes (PUBLISH_CONCURRENCY)
+	PublishTimeout          time.Duration            // default per-platform timeout (PUBLISH_TIMEOUT)
+	PublishPlatformTimeouts map[string]time.Duration // per-platform timeouts (PUBLISH_TIMEOUT_BY_PLATFORM=instagram=5m)
+
+	// Media endpoint: platforms that download media get short-lived signed URLs of local copies
+	MediaBaseURL    string        // public URL of the HTTP server for media (MEDIA_BASE_URL, default PUBLIC_BASE_URL)
+	MediaCacheDir   string        // where media is cached (MEDIA_CACHE_DIR, default a directory in the temp dir)
+	MediaURLTTL     time.Duration // validity of media URLs (MEDIA_URL_TTL)
+	MediaSigningKey string        // HMAC key of media URLs (MEDIA_SIGNING_KEY); random per process if unset
+
+	// Master keys encrypting stored account credentials (SECRETS_MASTER_KEYS or SECRETS_MASTER_KEY_FILE); nil if unset
+	SecretKeys *secrets.Keyring
+
+	// Owners of stored accounts are warned this long before a token that cannot be refreshed expires
+	TokenExpiryWarning time.Duration // TOKEN_EXPIRY_WARNING
+
 	// Twitter (X) credentials (OAuth 1.0a user context)
 	TwitterConsumerKey    string
 	TwitterConsumerSecret string
 	TwitterAccessToken    string
 	TwitterAccessSecret   string
+	TwitterOAuth2Token    string // OAuth 2.0 user token of an account connected with /connect; replaces the keys above
+
+	// OAuth apps used by /connect to link accounts
+	TwitterClientID      string
+	TwitterClientSecret  string
+	PinterestAppID       string
+	PinterestAppSecret   string
+	MetaAppID            string // Facebook Pages and Instagram
+	MetaAppSecret        string
+	LinkedInClientID     string
+	LinkedInClientSecret string
 
 	// Pinterest
 	PinterestAccessToken string
@@ -41,6 +85,37 @@ type Config struct {
 	// Instagram (IG Graph API)
 	InstagramAccessToken string
 	InstagramUserID      string
+	// Reels: also show them in the feed, and the cover frame in milliseconds (0 = Instagram's default)
+	InstagramReelsShareToFeed bool
+	InstagramReelsThumbOffset int
+
+	// Threads (Threads API)
+	ThreadsAccessToken string
+	ThreadsUserID      string
+
+	// TikTok (Content Posting API)
+	TikTokAccessToken  string
+	TikTokPrivacyLevel string // privacy_level of direct posts (default SELF_ONLY)
+	TikTokPullFromURL  bool   // let TikTok pull videos from the media endpoint instead of uploading them
+
+	// Mastodon (any instance)
+	MastodonInstanceURL string
+	MastodonAccessToken string
+	MastodonVisibility  string // public | unlisted | private | direct
+	MastodonSpoilerText string // content warning added to every status
+	MastodonLanguage    string // ISO 639 code of statuses
+
+	// Bluesky (AT Protocol)
+	BlueskyHandle      string
+	BlueskyAppPassword string
+	BlueskyPDSURL      string // defaults to https://bsky.social
+
+	// LinkedIn (organization page)
+	LinkedInAccessToken  string
+	LinkedInOrganization string // organization URN or numeric ID
+
+	// Telegram channels the bot cross-posts to (the bot must be an admin there)
+	TelegramChannelIDs []int64
 }
 
 // Load loads configuration from environment variables
@@ -63,10 +138,15 @@ func Load() (*Config, error) {
 
 	// Optional: Set webhook URL if provided
 	webhookURL := os.Getenv("WEBHOOK_URL")
-	if webhookURL != "" {
+	config.PublicBaseURL = strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	config.MediaBaseURL = strings.TrimRight(os.Getenv("MEDIA_BASE_URL"), "/")
+	if config.MediaBaseURL == "" {
+		config.MediaBaseURL = config.PublicBaseURL
+	}
+	if webhookURL != "" || config.MediaBaseURL != "" {
 		config.WebhookURL = webhookURL
 
-		// If webhook is used, get port
+		// If webhook, OAuth callbacks or the media endpoint are used, get port
 		port := os.Getenv("PORT")
 		if port == "" {
 			port = "8443" // Default webhook port
@@ -74,39 +154,22 @@ func Load() (*Config, error) {
 		config.WebhookPort = port
 	}
 
-	// Optional: Allowed users (comma-separated int64 list)
+	// Optional: Allowed users and account admins (comma-separated int64 lists)
 	if allowed := os.Getenv("ALLOWED_USERS"); allowed != "" {
-		var ids []int64
-		var cur int64
-		var neg bool
-		// Simple fast parser for digits, commas, optional spaces and leading '-'
-		for i := 0; i < len(allowed); i++ {
-			c := allowed[i]
-			switch {
-			case c >= '0' && c <= '9':
-				cur = cur*10 + int64(c-'0')
-			case c == '-':
-				if cur == 0 {
-					neg = true
-				}
-			default:
-				if neg {
-					cur = -cur
-				}
-				if cur != 0 {
-					ids = append(ids, cur)
-				}
-				cur = 0
-				neg = false
-			}
-		}
-		if neg {
-			cur = -cur
-		}
-		if cur != 0 {
-			ids = append(ids, cur)
+		config.AllowedUsers = parseUserIDs(allowed)
+	}
+	if admins := os.Getenv("ACCOUNT_ADMINS"); admins != "" {
+		config.AccountAdmins = parseUserIDs(admins)
+	}
+
+	// Optional: timezone for scheduling (IANA name, e.g. Europe/Berlin)
+	config.Timezone = time.UTC
+	if tz := os.Getenv("TIMEZONE"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TIMEZONE %q: %w", tz, err)
 		}
-		config.AllowedUsers = ids
+		config.Timezone = loc
 	}
 
 	// Database configuration
@@ -132,12 +195,99 @@ func Load() (*Config, error) {
 		config.DBName = os.Getenv("DB_NAME")
 	}
 
+	// Publish retries
+	config.RetryMaxAttempts = 5
+	if v := os.Getenv("PUBLISH_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid PUBLISH_MAX_ATTEMPTS %q", v)
+		}
+		config.RetryMaxAttempts = n
+	}
+	config.RetryPlatformMaxAttempts = map[string]int{}
+	for platform, v := range parsePlatformValues(os.Getenv("PUBLISH_MAX_ATTEMPTS_BY_PLATFORM")) {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid PUBLISH_MAX_ATTEMPTS_BY_PLATFORM value for %s: %q", platform, v)
+		}
+		config.RetryPlatformMaxAttempts[platform] = n
+	}
+	var err error
+	if config.RetryBaseDelay, err = durationEnv("PUBLISH_RETRY_BASE_DELAY", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if config.RetryMaxDelay, err = durationEnv("PUBLISH_RETRY_MAX_DELAY", 30*time.Minute); err != nil {
+		return nil, err
+	}
+
+	// Publish fan-out
+	config.PublishConcurrency = 4
+	if v := os.Getenv("PUBLISH_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid PUBLISH_CONCURRENCY %q", v)
+		}
+		config.PublishConcurrency = n
+	}
+	if config.PublishTimeout, err = durationEnv("PUBLISH_TIMEOUT", 2*time.Minute); err != nil {
+		return nil, err
+	}
+	if config.PublishTimeout >= PostPublishTimeout {
+		return nil, fmt.Errorf("PUBLISH_TIMEOUT must be less than %s", PostPublishTimeout)
+	}
+	config.PublishPlatformTimeouts = map[string]time.Duration{}
+	for platform, v := range parsePlatformValues(os.Getenv("PUBLISH_TIMEOUT_BY_PLATFORM")) {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d >= PostPublishTimeout {
+			return nil, fmt.Errorf("invalid PUBLISH_TIMEOUT_BY_PLATFORM value for %s: %q (must be less than %s)", platform, v, PostPublishTimeout)
+		}
+		config.PublishPlatformTimeouts[platform] = d
+	}
+
+	config.MediaCacheDir = os.Getenv("MEDIA_CACHE_DIR")
+	if config.MediaCacheDir == "" {
+		config.MediaCacheDir = filepath.Join(os.TempDir(), "trinity_bot-media")
+	}
+	if config.MediaURLTTL, err = durationEnv("MEDIA_URL_TTL", time.Hour); err != nil {
+		return nil, err
+	}
+	config.MediaSigningKey = os.Getenv("MEDIA_SIGNING_KEY")
+
+	keySpec := os.Getenv("SECRETS_MASTER_KEYS")
+	if path := os.Getenv("SECRETS_MASTER_KEY_FILE"); path != "" {
+		if keySpec != "" {
+			return nil, errors.New("set either SECRETS_MASTER_KEYS or SECRETS_MASTER_KEY_FILE, not both")
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read SECRETS_MASTER_KEY_FILE: %w", err)
+		}
+		keySpec = string(raw)
+	}
+	if config.SecretKeys, err = secrets.ParseKeyring(keySpec); err != nil {
+		return nil, fmt.Errorf("invalid master keys: %w", err)
+	}
+
+	if config.TokenExpiryWarning, err = durationEnv("TOKEN_EXPIRY_WARNING", 72*time.Hour); err != nil {
+		return nil, err
+	}
+
 	// Twitter credentials
 	config.TwitterConsumerKey = os.Getenv("TWITTER_CONSUMER_KEY")
 	config.TwitterConsumerSecret = os.Getenv("TWITTER_CONSUMER_SECRET")
 	config.TwitterAccessToken = os.Getenv("TWITTER_ACCESS_TOKEN")
 	config.TwitterAccessSecret = os.Getenv("TWITTER_ACCESS_SECRET")
 
+	// OAuth apps for /connect
+	config.TwitterClientID = os.Getenv("TWITTER_CLIENT_ID")
+	config.TwitterClientSecret = os.Getenv("TWITTER_CLIENT_SECRET")
+	config.PinterestAppID = os.Getenv("PINTEREST_APP_ID")
+	config.PinterestAppSecret = os.Getenv("PINTEREST_APP_SECRET")
+	config.MetaAppID = os.Getenv("META_APP_ID")
+	config.MetaAppSecret = os.Getenv("META_APP_SECRET")
+	config.LinkedInClientID = os.Getenv("LINKEDIN_CLIENT_ID")
+	config.LinkedInClientSecret = os.Getenv("LINKEDIN_CLIENT_SECRET")
+
 	// Pinterest
 	config.PinterestAccessToken = os.Getenv("PINTEREST_ACCESS_TOKEN")
 	config.PinterestBoardID = os.Getenv("PINTEREST_BOARD_ID")
@@ -147,6 +297,164 @@ func Load() (*Config, error) {
 	config.FacebookPageID = os.Getenv("FACEBOOK_PAGE_ID")
 	config.InstagramAccessToken = os.Getenv("INSTAGRAM_ACCESS_TOKEN")
 	config.InstagramUserID = os.Getenv("INSTAGRAM_USER_ID")
+	config.InstagramReelsShareToFeed = true
+	if v := os.Getenv("INSTAGRAM_REELS_SHARE_TO_FEED"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INSTAGRAM_REELS_SHARE_TO_FEED %q", v)
+		}
+		config.InstagramReelsShareToFeed = b
+	}
+	if v := os.Getenv("INSTAGRAM_REELS_THUMB_OFFSET"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid INSTAGRAM_REELS_THUMB_OFFSET %q", v)
+		}
+		config.InstagramReelsThumbOffset = n
+	}
+
+	// Threads
+	config.ThreadsAccessToken = os.Getenv("THREADS_ACCESS_TOKEN")
+	config.ThreadsUserID = os.Getenv("THREADS_USER_ID")
+
+	// TikTok
+	config.TikTokAccessToken = os.Getenv("TIKTOK_ACCESS_TOKEN")
+	config.TikTokPrivacyLevel = os.Getenv("TIKTOK_PRIVACY_LEVEL")
+	if config.TikTokPrivacyLevel == "" {
+		config.TikTokPrivacyLevel = "SELF_ONLY"
+	}
+	if v := os.Getenv("TIKTOK_PULL_FROM_URL"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TIKTOK_PULL_FROM_URL %q", v)
+		}
+		config.TikTokPullFromURL = b
+	}
+
+	// Mastodon
+	config.MastodonInstanceURL = os.Getenv("MASTODON_INSTANCE_URL")
+	config.MastodonAccessToken = os.Getenv("MASTODON_ACCESS_TOKEN")
+	config.MastodonVisibility = os.Getenv("MASTODON_VISIBILITY")
+	switch config.MastodonVisibility {
+	case "", "public", "unlisted", "private", "direct":
+	default:
+		return nil, fmt.Errorf("invalid MASTODON_VISIBILITY %q", config.MastodonVisibility)
+	}
+	config.MastodonSpoilerText = os.Getenv("MASTODON_SPOILER_TEXT")
+	config.MastodonLanguage = os.Getenv("MASTODON_LANGUAGE")
+
+	// Bluesky
+	config.BlueskyHandle = os.Getenv("BLUESKY_HANDLE")
+	config.BlueskyAppPassword = os.Getenv("BLUESKY_APP_PASSWORD")
+	config.BlueskyPDSURL = os.Getenv("BLUESKY_PDS_URL")
+
+	// LinkedIn
+	config.LinkedInAccessToken = os.Getenv("LINKEDIN_ACCESS_TOKEN")
+	config.LinkedInOrganization = os.Getenv("LINKEDIN_ORGANIZATION_URN")
+
+	// Telegram channels (comma-separated chat IDs, e.g. -1001234567890)
+	for _, v := range strings.Split(os.Getenv("TELEGRAM_CHANNEL_IDS"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TELEGRAM_CHANNEL_IDS entry %q", v)
+		}
+		config.TelegramChannelIDs = append(config.TelegramChannelIDs, id)
+	}
 
 	return config, nil
 }
+
+// MaxAttempts returns the publish attempt cap for a platform.
+func (c *Config) MaxAttempts(platform string) int {
+	if n, ok := c.RetryPlatformMaxAttempts[platform]; ok {
+		return n
+	}
+	return c.RetryMaxAttempts
+}
+
+// PostPublishTimeout bounds one post's whole fan-out, including the wait for a publish slot.
+// Per-platform timeouts must stay below it.
+const PostPublishTimeout = 10 * time.Minute
+
+// minPlatformTimeouts are the default timeouts of platforms that wait for media processing,
+// long enough for the connector's own processing wait (its readyTimeout) to run out first.
+var minPlatformTimeouts = map[string]time.Duration{
+	"instagram": 6 * time.Minute,
+	"pinterest": 6 * time.Minute,
+	"threads":   6 * time.Minute,
+	"tiktok":    8 * time.Minute,
+}
+
+// PublishTimeoutFor returns the publish timeout for a platform: PUBLISH_TIMEOUT_BY_PLATFORM if set,
+// otherwise PUBLISH_TIMEOUT raised to the platform's minimum.
+func (c *Config) PublishTimeoutFor(platform string) time.Duration {
+	if d, ok := c.PublishPlatformTimeouts[platform]; ok {
+		return d
+	}
+	return max(c.PublishTimeout, minPlatformTimeouts[platform])
+}
+
+// parsePlatformValues parses "platform=value" pairs separated by commas, e.g. "twitter=3,instagram=5".
+func parsePlatformValues(raw string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		k, v = strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// parseUserIDs parses a comma-separated list of Telegram user IDs.
+func parseUserIDs(s string) []int64 {
+	var ids []int64
+	var cur int64
+	var neg bool
+	// Simple fast parser for digits, commas, optional spaces and leading '-'
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			cur = cur*10 + int64(c-'0')
+		case c == '-':
+			if cur == 0 {
+				neg = true
+			}
+		default:
+			if neg {
+				cur = -cur
+			}
+			if cur != 0 {
+				ids = append(ids, cur)
+			}
+			cur = 0
+			neg = false
+		}
+	}
+	if neg {
+		cur = -cur
+	}
+	if cur != 0 {
+		ids = append(ids, cur)
+	}
+	return ids
+}
+
+// durationEnv reads a Go duration (e.g. "30s", "5m") from env, falling back to def when unset.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return d, nil
+}
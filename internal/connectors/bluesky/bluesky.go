@@ -0,0 +1,255 @@
+package bluesky
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+const defaultPDS = "https://bsky.social"
+
+// Limits of app.bsky.feed.post
+const (
+	maxImages    = 4
+	maxBlobBytes = 1000000
+	maxTextRunes = 300 // the API counts graphemes; runes are a conservative approximation
+)
+
+type Client struct {
+	httpClient *http.Client
+	host       string
+	identifier string
+	password   string
+
+	did       string
+	accessJwt string
+}
+
+type Credentials struct {
+	Identifier  string // handle or DID
+	AppPassword string
+	PDSURL      string // defaults to https://bsky.social
+}
+
+// Image is an image to embed in a post.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Alt         string
+}
+
+func New(creds Credentials) (*Client, error) {
+	if creds.Identifier == "" || creds.AppPassword == "" {
+		return nil, connectors.AuthError("bluesky", "bluesky handle or app password missing")
+	}
+	host := strings.TrimRight(creds.PDSURL, "/")
+	if host == "" {
+		host = defaultPDS
+	}
+	return &Client{
+		httpClient: &http.Client{Timeout: 25 * time.Second},
+		host:       host,
+		identifier: creds.Identifier,
+		password:   creds.AppPassword,
+	}, nil
+}
+
+// Post creates an app.bsky.feed.post record with the text, its link/mention/hashtag facets
+// and up to 4 images. Returns the record's AT URI.
+func (c *Client) Post(ctx context.Context, text string, images []Image) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("bluesky client not initialized")
+	}
+	if n := len([]rune(text)); n > maxTextRunes {
+		return "", connectors.ValidationError("bluesky", fmt.Sprintf("bluesky posts are limited to %d characters (got %d)", maxTextRunes, n))
+	}
+	if len(images) > maxImages {
+		images = images[:maxImages]
+	}
+	if err := c.createSession(ctx); err != nil {
+		return "", err
+	}
+
+	type embedImage struct {
+		Alt   string          `json:"alt"`
+		Image json.RawMessage `json:"image"`
+	}
+	var embedded []embedImage
+	for i, img := range images {
+		if len(img.Data) > maxBlobBytes {
+			return "", connectors.ValidationError("bluesky", fmt.Sprintf("bluesky image %d is larger than 1 MB", i+1))
+		}
+		blob, err := c.uploadBlob(ctx, img.Data, img.ContentType)
+		if err != nil {
+			return "", err
+		}
+		embedded = append(embedded, embedImage{Alt: img.Alt, Image: blob})
+	}
+
+	record := map[string]any{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if facets := c.facets(ctx, text); len(facets) > 0 {
+		record["facets"] = facets
+	}
+	if len(embedded) > 0 {
+		record["embed"] = map[string]any{"$type": "app.bsky.embed.images", "images": embedded}
+	}
+
+	var out struct {
+		URI string `json:"uri"`
+		CID string `json:"cid"`
+	}
+	in := map[string]any{"repo": c.did, "collection": "app.bsky.feed.post", "record": record}
+	if err := c.xrpc(ctx, "com.atproto.repo.createRecord", in, &out); err != nil {
+		return "", fmt.Errorf("bluesky create post: %w", err)
+	}
+	if out.URI == "" {
+		return "", errors.New("bluesky create post: missing uri in response")
+	}
+	return out.URI, nil
+}
+
+// createSession logs in with the app password.
+func (c *Client) createSession(ctx context.Context) error {
+	var out struct {
+		DID       string `json:"did"`
+		AccessJwt string `json:"accessJwt"`
+	}
+	in := map[string]string{"identifier": c.identifier, "password": c.password}
+	if err := c.xrpc(ctx, "com.atproto.server.createSession", in, &out); err != nil {
+		return fmt.Errorf("bluesky login: %w", err)
+	}
+	c.did, c.accessJwt = out.DID, out.AccessJwt
+	return nil
+}
+
+// uploadBlob uploads an image and returns the blob reference to embed.
+func (c *Client) uploadBlob(ctx context.Context, data []byte, contentType string) (json.RawMessage, error) {
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = "image/jpeg"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+"/xrpc/com.atproto.repo.uploadBlob", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	var out struct {
+		Blob json.RawMessage `json:"blob"`
+	}
+	if err := c.do(req, &out); err != nil {
+		return nil, fmt.Errorf("bluesky upload blob: %w", err)
+	}
+	return out.Blob, nil
+}
+
+// facets converts detected entities to app.bsky.richtext.facet objects. Mentions of handles
+// that don't resolve to a DID are left as plain text.
+func (c *Client) facets(ctx context.Context, text string) []map[string]any {
+	var out []map[string]any
+	for _, s := range detectFacets(text) {
+		var feature map[string]any
+		switch s.kind {
+		case "link":
+			feature = map[string]any{"$type": "app.bsky.richtext.facet#link", "uri": s.value}
+		case "mention":
+			did, err := c.resolveHandle(ctx, s.value)
+			if err != nil {
+				continue
+			}
+			feature = map[string]any{"$type": "app.bsky.richtext.facet#mention", "did": did}
+		case "tag":
+			feature = map[string]any{"$type": "app.bsky.richtext.facet#tag", "tag": s.value}
+		}
+		out = append(out, map[string]any{
+			"index":    map[string]int{"byteStart": s.start, "byteEnd": s.end},
+			"features": []map[string]any{feature},
+		})
+	}
+	return out
+}
+
+func (c *Client) resolveHandle(ctx context.Context, handle string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+"/xrpc/com.atproto.identity.resolveHandle?handle="+url.QueryEscape(handle), nil)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		DID string `json:"did"`
+	}
+	if err := c.do(req, &out); err != nil {
+		return "", err
+	}
+	return out.DID, nil
+}
+
+// xrpc POSTs a JSON procedure call.
+func (c *Client) xrpc(ctx context.Context, method string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.host+"/xrpc/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, out)
+}
+
+// do sends a request, authorized once a session exists, and decodes a 2xx JSON response into out.
+func (c *Client) do(req *http.Request, out any) error {
+	if c.accessJwt != "" {
+		req.Header.Set("Authorization", "Bearer "+c.accessJwt)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return connectors.NetworkError("bluesky", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return apiError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// apiError classifies an XRPC error response. Rate limits carry ratelimit-reset (Unix seconds).
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var e struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		msg = e.Error + ": " + e.Message
+	}
+	ce := connectors.StatusError("bluesky", resp, fmt.Errorf("status %d: %s", resp.StatusCode, msg))
+	switch e.Error {
+	case "ExpiredToken", "InvalidToken", "AuthenticationRequired", "AccountTakedown":
+		ce.Kind = connectors.KindAuth
+	}
+	if ce.Kind == connectors.KindRateLimited && ce.RetryAfter == 0 {
+		if secs, err := strconv.ParseInt(resp.Header.Get("ratelimit-reset"), 10, 64); err == nil {
+			if d := time.Until(time.Unix(secs, 0)); d > 0 {
+				ce.RetryAfter = d
+			}
+		}
+	}
+	return ce
+}
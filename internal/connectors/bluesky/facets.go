@@ -0,0 +1,79 @@
+package bluesky
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Patterns follow the Bluesky rich text docs. Each has one capture group holding the entity,
+// so the leading boundary character is not part of the facet. The boundary is any character that
+// is not a Unicode letter, digit or underscore (RE2's \W is ASCII-only, so "é@handle" would match).
+// Go regexp offsets are byte offsets into the UTF-8 text, which is what facet indexes use.
+var (
+	linkRe    = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])(https?://(?:www\.)?[-a-zA-Z0-9@:%._+~#=]{1,256}\.[a-zA-Z0-9()]{1,6}\b(?:[-a-zA-Z0-9()@:%_+.~#?&/=]*[-a-zA-Z0-9@%_+~#/=])?)`)
+	mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])(@(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)`)
+	tagRe     = regexp.MustCompile(`(?:^|\s)([#＃][^\s#＃]+)`)
+)
+
+// maxTagRunes is the longest hashtag Bluesky indexes (without the #).
+const maxTagRunes = 64
+
+// span is a detected entity: [start, end) byte offsets and its value
+// (URL, handle without @, or tag without #).
+type span struct {
+	start, end int
+	kind       string // "link" | "mention" | "tag"
+	value      string
+}
+
+// detectFacets finds links, mentions and hashtags in text, ordered by position. Links are
+// detected first and later matches overlapping an earlier one are dropped, so "@" or "#" inside
+// a URL does not become a mention or tag.
+func detectFacets(text string) []span {
+	var out []span
+	overlaps := func(start, end int) bool {
+		for _, s := range out {
+			if start < s.end && s.start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, m := range linkRe.FindAllStringSubmatchIndex(text, -1) {
+		out = append(out, span{start: m[2], end: m[3], kind: "link", value: text[m[2]:m[3]]})
+	}
+	for _, m := range mentionRe.FindAllStringSubmatchIndex(text, -1) {
+		if overlaps(m[2], m[3]) {
+			continue
+		}
+		out = append(out, span{start: m[2], end: m[3], kind: "mention", value: text[m[2]+1 : m[3]]})
+	}
+	for _, m := range tagRe.FindAllStringSubmatchIndex(text, -1) {
+		start, end := m[2], m[3]
+		// Trailing punctuation ends the tag ("#golang," -> "golang")
+		tag := strings.TrimRightFunc(text[start:end], unicode.IsPunct)
+		end = start + len(tag)
+		_, hashLen := utf8.DecodeRuneInString(tag)
+		value := tag[hashLen:]
+		if value == "" || isDigits(value) || utf8.RuneCountInString(value) > maxTagRunes || overlaps(start, end) {
+			continue
+		}
+		out = append(out, span{start: start, end: end, kind: "tag", value: value})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].start < out[j].start })
+	return out
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
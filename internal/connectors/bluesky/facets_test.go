@@ -0,0 +1,63 @@
+package bluesky
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectFacets(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []span
+	}{
+		{"link", "hello https://example.com",
+			[]span{{6, 25, "link", "https://example.com"}}},
+		{"link after emoji", "😀 https://example.com/path",
+			[]span{{5, 29, "link", "https://example.com/path"}}},
+		{"link in parentheses", "see (https://example.com) now",
+			[]span{{5, 24, "link", "https://example.com"}}},
+		{"link glued to accented letter", "caféhttps://example.com", nil},
+		{"mention in link", "https://example.com/@alice.bsky.social",
+			[]span{{0, 38, "link", "https://example.com/@alice.bsky.social"}}},
+		{"mention after CJK and space", "日本語 @alice.bsky.social",
+			[]span{{10, 28, "mention", "alice.bsky.social"}}},
+		{"mention glued to accented letter", "é@alice.bsky.social", nil},
+		{"mention glued to CJK", "日本@alice.bsky.social", nil},
+		{"mention after emoji", "👍@alice.bsky.social",
+			[]span{{4, 22, "mention", "alice.bsky.social"}}},
+		{"email is not a mention", "mail bob@example.com", nil},
+		{"tag after accented word", "café #tag",
+			[]span{{6, 10, "tag", "tag"}}},
+		{"CJK tag and trailing punctuation", "#日本語 and #golang,",
+			[]span{{0, 10, "tag", "日本語"}, {15, 22, "tag", "golang"}}},
+		{"full-width hash", "full-width ＃タグ",
+			[]span{{11, 20, "tag", "タグ"}}},
+		{"numeric tag", "#123 #v2",
+			[]span{{5, 8, "tag", "v2"}}},
+		{"all kinds in order", "👋 @bob.example.org see https://x.org #go",
+			[]span{{5, 21, "mention", "bob.example.org"}, {26, 39, "link", "https://x.org"}, {40, 43, "tag", "go"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectFacets(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("detectFacets(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+			// Offsets are UTF-8 byte offsets of the whole entity, including @ and #
+			for _, s := range got {
+				entity := tt.text[s.start:s.end]
+				switch s.kind {
+				case "link":
+					if entity != s.value {
+						t.Errorf("link bytes %d-%d = %q, want %q", s.start, s.end, entity, s.value)
+					}
+				default:
+					if len(entity) <= len(s.value) || entity[len(entity)-len(s.value):] != s.value {
+						t.Errorf("%s bytes %d-%d = %q, value %q", s.kind, s.start, s.end, entity, s.value)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package bluesky
+
+import (
+	"context"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+func init() {
+	connectors.Register("bluesky", newPublisher)
+}
+
+type publisher struct {
+	client *Client
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.BlueskyHandle == "" || cfg.BlueskyAppPassword == "" {
+		return nil, connectors.AuthError("bluesky", "Bluesky credentials missing")
+	}
+	cli, err := New(Credentials{
+		Identifier:  cfg.BlueskyHandle,
+		AppPassword: cfg.BlueskyAppPassword,
+		PDSURL:      cfg.BlueskyPDSURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{client: cli}, nil
+}
+
+// Publish posts the text with the first 4 photos and their alt text.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	photos := post.Photos()
+	if len(photos) > maxImages {
+		photos = photos[:maxImages]
+	}
+	var images []Image
+	for _, m := range photos {
+		data, ctype, err := post.Files.Fetch(ctx, m.FileID)
+		if err != nil {
+			return "", err
+		}
+		images = append(images, Image{Data: data, ContentType: ctype, Alt: m.Description})
+	}
+	if post.Text == "" && len(images) == 0 {
+		return "", connectors.ValidationError("bluesky", "Bluesky post needs text or photos")
+	}
+	return p.client.Post(ctx, post.Text, images)
+}
@@ -0,0 +1,128 @@
+// Package connectors defines what the platform connectors share: the Publisher interface and
+// registry that drive publishing, and typed errors.
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"trinity_bot/internal/config"
+)
+
+// Media is one attachment of a post, identified by its Telegram file ID.
+type Media struct {
+	FileID      string
+	Type        string // 'photo' | 'video'
+	Description string // alt text, may be empty
+}
+
+// MediaSource gives publishers access to attachment contents.
+type MediaSource interface {
+	// Fetch downloads a file and returns its bytes and content type.
+	Fetch(ctx context.Context, fileID string) ([]byte, string, error)
+	// URL returns a publicly reachable URL for platforms that pull media themselves.
+	URL(ctx context.Context, fileID string) (string, error)
+}
+
+// Post is the platform-independent form of a post handed to publishers.
+type Post struct {
+	ID    int64
+	Text  string
+	Media []Media // in draft order
+	Files MediaSource
+	Link  string // destination URL, may be empty
+	// Pinterest board and section chosen for the post; empty means the configured default
+	PinterestBoardID   string
+	PinterestSectionID string
+	// ScheduledAt is set when a scheduled post is handed to a NativeScheduler ahead of time;
+	// the platform then publishes it itself at that time.
+	ScheduledAt *time.Time
+}
+
+// Photos returns the photo attachments in order.
+func (p *Post) Photos() []Media { return p.mediaOfType("photo") }
+
+// Videos returns the video attachments in order.
+func (p *Post) Videos() []Media { return p.mediaOfType("video") }
+
+func (p *Post) mediaOfType(t string) []Media {
+	var out []Media
+	for _, m := range p.Media {
+		if strings.EqualFold(m.Type, t) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// Publisher publishes a post to one platform and returns the platform's ID for it.
+type Publisher interface {
+	Publish(ctx context.Context, post *Post) (string, error)
+}
+
+// NativeScheduler is implemented by publishers that can hand a scheduled post over to the
+// platform's own scheduling, so that it shows up in the platform's planner. Publish schedules
+// the post when Post.ScheduledAt is set.
+type NativeScheduler interface {
+	Publisher
+	// CanSchedule reports whether the platform accepts a post scheduled for at.
+	CanSchedule(at time.Time) bool
+	// Unschedule deletes a natively scheduled post, given the ID Publish returned for it.
+	// A post made of several objects that is only partly deleted fails with *UnscheduleError.
+	Unschedule(ctx context.Context, externalID string) error
+}
+
+// UnscheduleError reports an Unschedule that deleted only some objects of a post. Remaining is
+// the external ID of what is still scheduled, in the form Publish returned.
+type UnscheduleError struct {
+	Remaining string
+	Err       error
+}
+
+func (e *UnscheduleError) Error() string { return e.Err.Error() }
+
+func (e *UnscheduleError) Unwrap() error { return e.Err }
+
+// Factory builds a platform's Publisher from the configuration. It fails when the
+// platform's credentials are missing.
+type Factory func(cfg *config.Config) (Publisher, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a platform's publisher available under the name used in storage.Platforms.
+// Connector packages call it from init; registering a name twice panics.
+func Register(platform string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[platform]; dup {
+		panic(fmt.Sprintf("connectors: %s registered twice", platform))
+	}
+	registry[platform] = f
+}
+
+// Lookup returns the factory registered for a platform.
+func Lookup(platform string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := registry[platform]
+	return f, ok
+}
+
+// Registered lists the registered platform names, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
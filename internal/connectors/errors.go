@@ -0,0 +1,146 @@
+package connectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Kind classifies a connector failure so the publish worker can tell whether a failed call
+// is worth retrying.
+type Kind int
+
+const (
+	KindUnknown     Kind = iota
+	KindAuth             // credentials missing, invalid or expired
+	KindRateLimited      // throttled by the platform; see Error.RetryAfter
+	KindValidation       // the platform rejected the content or request
+	KindTransient        // network failure, timeout or 5xx
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindAuth:
+		return "auth"
+	case KindRateLimited:
+		return "rate_limited"
+	case KindValidation:
+		return "validation"
+	case KindTransient:
+		return "transient"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is a typed connector failure. Its message is the wrapped error's message.
+type Error struct {
+	Platform   string
+	Kind       Kind
+	StatusCode int           // HTTP status, if the failure came from a response
+	RetryAfter time.Duration // server-requested delay for rate limits, if any
+	Err        error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// StatusError classifies a non-2xx HTTP response. err carries the message to report.
+func StatusError(platform string, resp *http.Response, err error) *Error {
+	e := &Error{Platform: platform, Kind: KindForStatus(resp.StatusCode), StatusCode: resp.StatusCode, Err: err}
+	if e.Kind == KindRateLimited || e.Kind == KindTransient {
+		e.RetryAfter = ParseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	return e
+}
+
+// NetworkError wraps a transport-level failure (connection reset, DNS, timeout) as transient.
+// Cancellation by the caller is not retried. The request URL is dropped (see StripURL).
+func NetworkError(platform string, err error) error {
+	err = StripURL(err)
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return &Error{Platform: platform, Kind: KindTransient, Err: err}
+}
+
+// StripURL drops the request URL from a transport error. Several APIs take access tokens in the
+// query string (Graph API, Telegram), and errors end up in post_targets, logs and chat messages.
+func StripURL(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		return fmt.Errorf("%s: %w", ue.Op, ue.Err)
+	}
+	return err
+}
+
+// ValidationError reports content the platform cannot accept (e.g. a pin without an image).
+func ValidationError(platform string, msg string) error {
+	return &Error{Platform: platform, Kind: KindValidation, Err: errors.New(msg)}
+}
+
+// AuthError reports missing or rejected credentials.
+func AuthError(platform string, msg string) error {
+	return &Error{Platform: platform, Kind: KindAuth, Err: errors.New(msg)}
+}
+
+// KindForStatus maps an HTTP status code to an error kind.
+func KindForStatus(status int) Kind {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return KindAuth
+	case status == http.StatusTooManyRequests:
+		return KindRateLimited
+	case status == http.StatusRequestTimeout || status >= 500:
+		return KindTransient
+	case status >= 400:
+		return KindValidation
+	default:
+		return KindUnknown
+	}
+}
+
+// ParseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func ParseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// KindOf returns the kind of a connector error, or KindUnknown for untyped errors.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+// Retryable reports whether a failed call may succeed when repeated later.
+func Retryable(err error) bool {
+	k := KindOf(err)
+	return k == KindRateLimited || k == KindTransient
+}
+
+// RetryAfter returns the server-requested delay carried by err, if any.
+func RetryAfter(err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter
+	}
+	return 0
+}
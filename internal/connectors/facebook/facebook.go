@@ -6,73 +6,272 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+const (
+	graphHost = "https://graph.facebook.com/v19.0"
+	// Video uploads go to a dedicated host
+	videoHost = "https://graph-video.facebook.com/v19.0"
 )
 
-const graphHost = "https://graph.facebook.com/v19.0"
+// maxPhotos is how many photos are attached to a single Page post.
+const maxPhotos = 10
+
+// Facebook accepts scheduled_publish_time between 10 minutes and 30 days ahead.
+const (
+	minScheduleLead = 10 * time.Minute
+	maxScheduleLead = 30 * 24 * time.Hour
+)
 
 type Client struct {
-	httpClient  *http.Client
-	accessToken string
+	httpClient   *http.Client
+	accessToken  string
+	baseURL      string
+	videoBaseURL string
 }
 
 type Credentials struct {
 	AccessToken string
 }
 
+// Image is a photo to attach to a Page post.
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+// PostOptions are the optional settings of a Page post.
+type PostOptions struct {
+	// Link is shared with a link preview; only used for posts without images.
+	Link string
+	// ScheduledAt creates an unpublished post that Facebook publishes at that time.
+	ScheduledAt *time.Time
+}
+
 func New(creds Credentials) (*Client, error) {
 	if creds.AccessToken == "" {
-		return nil, errors.New("facebook access token missing")
+		return nil, connectors.AuthError("facebook", "facebook access token missing")
 	}
-	return &Client{httpClient: &http.Client{Timeout: 25 * time.Second}, accessToken: creds.AccessToken}, nil
+	return &Client{
+		httpClient:   &http.Client{Timeout: 25 * time.Second},
+		accessToken:  creds.AccessToken,
+		baseURL:      graphHost,
+		videoBaseURL: videoHost,
+	}, nil
 }
 
-// CreatePost posts to a Facebook Page. If image is provided, uploads a photo with optional caption; otherwise posts a text status.
+// CreatePost posts to a Facebook Page. A single image is uploaded as a photo with the message
+// as caption; several images are uploaded unpublished and attached to one feed post
+// (attached_media); without images a text status or link post is created.
 // Returns the created object id (photo id or post id).
-func (c *Client) CreatePost(ctx context.Context, pageID string, message string, image []byte, contentType string) (string, error) {
+func (c *Client) CreatePost(ctx context.Context, pageID string, message string, images []Image, opts PostOptions) (string, error) {
 	if c == nil || c.httpClient == nil {
 		return "", errors.New("facebook client not initialized")
 	}
 	if pageID == "" {
 		return "", errors.New("facebook page id missing")
 	}
-	if len(image) > 0 {
-		return c.uploadPhoto(ctx, pageID, message, image, contentType)
+	if len(images) > maxPhotos {
+		images = images[:maxPhotos]
+	}
+	if opts.ScheduledAt != nil {
+		if err := checkSchedule(*opts.ScheduledAt); err != nil {
+			return "", err
+		}
+	}
+	switch len(images) {
+	case 0:
+		return c.postFeed(ctx, pageID, message, nil, opts)
+	case 1:
+		fields := url.Values{}
+		if message != "" {
+			fields.Set("caption", message)
+		}
+		setPublishing(fields, opts.ScheduledAt)
+		return c.uploadPhoto(ctx, pageID, images[0], fields)
+	}
+	photoIDs := make([]string, 0, len(images))
+	for _, img := range images {
+		fields := url.Values{}
+		fields.Set("published", "false")
+		if opts.ScheduledAt != nil {
+			// Photos attached to a scheduled post must be uploaded as temporary
+			fields.Set("temporary", "true")
+		}
+		id, err := c.uploadPhoto(ctx, pageID, img, fields)
+		if err != nil {
+			return "", err
+		}
+		photoIDs = append(photoIDs, id)
+	}
+	opts.Link = ""
+	return c.postFeed(ctx, pageID, message, photoIDs, opts)
+}
+
+func checkSchedule(at time.Time) error {
+	lead := time.Until(at)
+	if lead < minScheduleLead || lead > maxScheduleLead {
+		return connectors.ValidationError("facebook", fmt.Sprintf("facebook posts can only be scheduled %s to %s ahead", minScheduleLead, maxScheduleLead))
+	}
+	return nil
+}
+
+// setPublishing makes a post unpublished and scheduled for at, or published right away when at is nil.
+func setPublishing(fields url.Values, at *time.Time) {
+	if at == nil {
+		fields.Set("published", "true")
+		return
+	}
+	fields.Set("published", "false")
+	fields.Set("scheduled_publish_time", strconv.FormatInt(at.Unix(), 10))
+}
+
+// DeletePost deletes a post, photo or video, e.g. a scheduled post before it is published.
+// The Page token goes in the Authorization header rather than the URL.
+func (c *Client) DeletePost(ctx context.Context, id string) error {
+	if c == nil || c.httpClient == nil {
+		return errors.New("facebook client not initialized")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.baseURL+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	var out struct {
+		Success bool `json:"success"`
+	}
+	if err := c.do(req, &out, "delete"); err != nil {
+		return err
+	}
+	if !out.Success {
+		return fmt.Errorf("facebook: %s not deleted", id)
+	}
+	return nil
+}
+
+// UploadVideo publishes a video on the Page using the resumable upload protocol: a start
+// phase announcing the size, transfer phases sending the chunks Facebook asks for, and a
+// finish phase carrying the description. With scheduledAt the video is published by Facebook
+// at that time. Returns the video id.
+func (c *Client) UploadVideo(ctx context.Context, pageID, description string, video []byte, scheduledAt *time.Time) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("facebook client not initialized")
+	}
+	if pageID == "" {
+		return "", errors.New("facebook page id missing")
+	}
+	if len(video) == 0 {
+		return "", errors.New("facebook: empty video")
+	}
+	if scheduledAt != nil {
+		if err := checkSchedule(*scheduledAt); err != nil {
+			return "", err
+		}
+	}
+	endpoint := fmt.Sprintf("%s/%s/videos", c.videoBaseURL, pageID)
+
+	form := url.Values{}
+	form.Set("upload_phase", "start")
+	form.Set("file_size", strconv.Itoa(len(video)))
+	var start struct {
+		VideoID         string `json:"video_id"`
+		UploadSessionID string `json:"upload_session_id"`
+		StartOffset     string `json:"start_offset"`
+		EndOffset       string `json:"end_offset"`
+	}
+	if err := c.postForm(ctx, endpoint, form, &start, "video start"); err != nil {
+		return "", err
+	}
+	if start.VideoID == "" || start.UploadSessionID == "" {
+		return "", errors.New("facebook: missing video upload session in response")
+	}
+
+	from, to := start.StartOffset, start.EndOffset
+	for from != to {
+		lo, err1 := strconv.Atoi(from)
+		hi, err2 := strconv.Atoi(to)
+		if err1 != nil || err2 != nil || lo < 0 || hi > len(video) || lo >= hi {
+			return "", fmt.Errorf("facebook: invalid video chunk range %s-%s", from, to)
+		}
+		var next struct {
+			StartOffset string `json:"start_offset"`
+			EndOffset   string `json:"end_offset"`
+		}
+		fields := url.Values{}
+		fields.Set("upload_phase", "transfer")
+		fields.Set("upload_session_id", start.UploadSessionID)
+		fields.Set("start_offset", from)
+		if err := c.postMultipart(ctx, endpoint, fields, "video_file_chunk", "chunk", "application/octet-stream", video[lo:hi], &next, "video transfer"); err != nil {
+			return "", err
+		}
+		from, to = next.StartOffset, next.EndOffset
+	}
+
+	form = url.Values{}
+	form.Set("upload_phase", "finish")
+	form.Set("upload_session_id", start.UploadSessionID)
+	if description != "" {
+		form.Set("description", description)
+	}
+	setPublishing(form, scheduledAt)
+	var finish struct {
+		Success bool `json:"success"`
 	}
-	return c.postFeed(ctx, pageID, message)
+	if err := c.postForm(ctx, endpoint, form, &finish, "video finish"); err != nil {
+		return "", err
+	}
+	if !finish.Success {
+		return "", errors.New("facebook: video upload not finished")
+	}
+	return start.VideoID, nil
 }
 
-func (c *Client) postFeed(ctx context.Context, pageID, message string) (string, error) {
+// postFeed creates a feed post, attaching the given unpublished photos.
+func (c *Client) postFeed(ctx context.Context, pageID, message string, photoIDs []string, opts PostOptions) (string, error) {
 	form := url.Values{}
 	if message != "" {
 		form.Set("message", message)
 	}
-	form.Set("access_token", c.accessToken)
-	endpoint := fmt.Sprintf("%s/%s/feed", graphHost, pageID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
-	if err != nil {
-		return "", err
+	if opts.Link != "" {
+		form.Set("link", opts.Link)
 	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+	for i, id := range photoIDs {
+		form.Set(fmt.Sprintf("attached_media[%d]", i), fmt.Sprintf(`{"media_fbid":%q}`, id))
+	}
+	setPublishing(form, opts.ScheduledAt)
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := c.postForm(ctx, fmt.Sprintf("%s/%s/feed", c.baseURL, pageID), form, &out, "feed"); err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("facebook feed status %d: %s", resp.StatusCode, string(body))
+	if out.ID == "" {
+		return "", errors.New("facebook: missing id in response")
+	}
+	return out.ID, nil
+}
+
+// uploadPhoto uploads a photo to the Page with the given fields (caption, published, ...).
+// Unpublished photos only become visible once attached to a feed post.
+func (c *Client) uploadPhoto(ctx context.Context, pageID string, image Image, fields url.Values) (string, error) {
+	contentType := image.ContentType
+	if contentType == "" {
+		contentType = "image/jpeg"
 	}
 	var out struct {
 		ID string `json:"id"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+	endpoint := fmt.Sprintf("%s/%s/photos", c.baseURL, pageID)
+	if err := c.postMultipart(ctx, endpoint, fields, "source", "image.jpg", contentType, image.Data, &out, "photo"); err != nil {
 		return "", err
 	}
 	if out.ID == "" {
@@ -81,57 +280,54 @@ func (c *Client) postFeed(ctx context.Context, pageID, message string) (string,
 	return out.ID, nil
 }
 
-func (c *Client) uploadPhoto(ctx context.Context, pageID, caption string, image []byte, contentType string) (string, error) {
+// postForm sends a form-encoded POST and decodes the JSON response into out.
+func (c *Client) postForm(ctx context.Context, endpoint string, form url.Values, out any, what string) error {
+	form.Set("access_token", c.accessToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, out, what)
+}
+
+// postMultipart sends fields and one file part as multipart/form-data.
+func (c *Client) postMultipart(ctx context.Context, endpoint string, fields url.Values, fileField, filename, contentType string, data []byte, out any, what string) error {
 	var buf bytes.Buffer
 	mw := multipart.NewWriter(&buf)
-	// caption
-	if caption != "" {
-		_ = mw.WriteField("caption", caption)
+	for k := range fields {
+		_ = mw.WriteField(k, fields.Get(k))
 	}
-	// published true by default; ensure it
-	_ = mw.WriteField("published", "true")
-	// access token
 	_ = mw.WriteField("access_token", c.accessToken)
 
-	// file part
 	fh := make(textproto.MIMEHeader)
-	fh.Set("Content-Disposition", "form-data; name=\"source\"; filename=\"image.jpg\"")
-	if contentType == "" {
-		contentType = "image/jpeg"
-	}
+	fh.Set("Content-Disposition", fmt.Sprintf("form-data; name=%q; filename=%q", fileField, filename))
 	fh.Set("Content-Type", contentType)
 	part, err := mw.CreatePart(fh)
 	if err != nil {
-		return "", err
+		return err
 	}
-	if _, err := part.Write(image); err != nil {
-		return "", err
+	if _, err := part.Write(data); err != nil {
+		return err
 	}
 	_ = mw.Close()
 
-	endpoint := fmt.Sprintf("%s/%s/photos", graphHost, pageID)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &buf)
 	if err != nil {
-		return "", err
+		return err
 	}
 	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return c.do(req, out, what)
+}
+
+func (c *Client) do(req *http.Request, out any, what string) error {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return connectors.NetworkError("facebook", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("facebook photo status %d: %s", resp.StatusCode, string(body))
-	}
-	var out struct {
-		ID string `json:"id"`
+		return connectors.GraphError("facebook", resp, what)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return "", err
-	}
-	if out.ID == "" {
-		return "", errors.New("facebook: missing id in response")
-	}
-	return out.ID, nil
+	return json.NewDecoder(resp.Body).Decode(out)
 }
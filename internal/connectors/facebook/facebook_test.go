@@ -0,0 +1,106 @@
+package facebook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"trinity_bot/internal/connectors"
+)
+
+const testToken = "EAAB-page-token"
+
+// fakeGraph answers DELETE /{id}; ids in fail are answered with a Graph error.
+type fakeGraph struct {
+	srv  *httptest.Server
+	mu   sync.Mutex
+	reqs []*http.Request
+}
+
+func newFakeGraph(t *testing.T, fail string) *fakeGraph {
+	f := &fakeGraph{}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.reqs = append(f.reqs, r)
+		f.mu.Unlock()
+		if r.Method != http.MethodDelete {
+			t.Errorf("method = %s", r.Method)
+		}
+		if strings.TrimPrefix(r.URL.Path, "/") == fail {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"Unsupported delete request","code":100}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":true}`))
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeGraph) publisher(t *testing.T) *publisher {
+	c, err := New(Credentials{AccessToken: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = f.srv.URL
+	return &publisher{client: c, pageID: "page-1"}
+}
+
+func TestDeletePostSendsTokenInHeader(t *testing.T) {
+	f := newFakeGraph(t, "")
+	if err := f.publisher(t).client.DeletePost(context.Background(), "123_456"); err != nil {
+		t.Fatal(err)
+	}
+	r := f.reqs[0]
+	if r.URL.Path != "/123_456" || r.URL.RawQuery != "" {
+		t.Errorf("request URL = %s", r.URL)
+	}
+	if got := r.Header.Get("Authorization"); got != "Bearer "+testToken {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	// A transport failure must not show the token either
+	f.srv.Close()
+	err := f.publisher(t).client.DeletePost(context.Background(), "123_456")
+	if err == nil || strings.Contains(err.Error(), testToken) {
+		t.Errorf("DeletePost error = %v", err)
+	}
+}
+
+func TestUnschedule(t *testing.T) {
+	tests := []struct {
+		name      string
+		fail      string
+		wantErr   bool
+		remaining string // "" when the failure is not partial
+	}{
+		{"all deleted", "", false, ""},
+		{"first fails", "1", true, ""},
+		{"second fails", "2", true, "2,3"},
+		{"last fails", "3", true, "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeGraph(t, tt.fail)
+			err := f.publisher(t).Unschedule(context.Background(), "1,2,3")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			var ue *connectors.UnscheduleError
+			partial := errors.As(err, &ue)
+			if partial != (tt.remaining != "") {
+				t.Fatalf("partial = %v, err = %v", partial, err)
+			}
+			if partial && ue.Remaining != tt.remaining {
+				t.Errorf("remaining = %q, want %q", ue.Remaining, tt.remaining)
+			}
+			if err != nil && connectors.KindOf(err) != connectors.KindValidation {
+				t.Errorf("kind = %s", connectors.KindOf(err))
+			}
+		})
+	}
+}
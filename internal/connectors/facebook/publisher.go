@@ -0,0 +1,120 @@
+package facebook
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+func init() {
+	connectors.Register("facebook", newPublisher)
+}
+
+// scheduleMargin is added to Facebook's minimum lead time so that a post handed over right
+// after /schedule is still accepted once the worker has uploaded its media.
+const scheduleMargin = 5 * time.Minute
+
+var linkRe = regexp.MustCompile(`https?://[^\s]+`)
+
+type publisher struct {
+	client *Client
+	pageID string
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.FacebookAccessToken == "" || cfg.FacebookPageID == "" {
+		return nil, connectors.AuthError("facebook", "Facebook access token or page ID missing")
+	}
+	cli, err := New(Credentials{AccessToken: cfg.FacebookAccessToken})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{client: cli, pageID: cfg.FacebookPageID}, nil
+}
+
+// Publish posts the text with all photos as one Page post, then every video as a video post
+// of its own, since feed posts cannot attach videos. The text goes on the photo post, or on the
+// first video when there are no photos; a text-only post shares the post's link,
+// or else the first link in the text, with a preview.
+// A post with ScheduledAt is created unpublished and published by Facebook at that time.
+// Returns the comma-joined ids in that order.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	var images []Image
+	for _, m := range post.Photos() {
+		if len(images) == maxPhotos {
+			break
+		}
+		data, ctype, err := post.Files.Fetch(ctx, m.FileID)
+		if err != nil {
+			return "", err
+		}
+		images = append(images, Image{Data: data, ContentType: ctype})
+	}
+	videos := post.Videos()
+
+	var ids []string
+	text := post.Text
+	if len(images) > 0 || len(videos) == 0 {
+		opts := PostOptions{ScheduledAt: post.ScheduledAt}
+		if len(images) == 0 {
+			opts.Link = post.Link
+			if opts.Link == "" {
+				opts.Link = linkRe.FindString(text)
+			}
+		}
+		id, err := p.client.CreatePost(ctx, p.pageID, text, images, opts)
+		if err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+		text = ""
+	}
+	for _, v := range videos {
+		id, err := p.uploadVideo(ctx, post, v, text)
+		if err != nil {
+			if len(ids) == 0 {
+				return "", err
+			}
+			// A retry would post the photos and earlier videos again
+			return "", &connectors.Error{Platform: "facebook", Kind: connectors.KindUnknown, Err: fmt.Errorf(
+				"video upload failed (posted: %s): %w", strings.Join(ids, ","), err)}
+		}
+		ids = append(ids, id)
+		text = ""
+	}
+	return strings.Join(ids, ","), nil
+}
+
+func (p *publisher) uploadVideo(ctx context.Context, post *connectors.Post, m connectors.Media, description string) (string, error) {
+	data, _, err := post.Files.Fetch(ctx, m.FileID)
+	if err != nil {
+		return "", err
+	}
+	return p.client.UploadVideo(ctx, p.pageID, description, data, post.ScheduledAt)
+}
+
+// CanSchedule reports whether at is far enough ahead, but not too far, for Facebook's scheduling.
+func (p *publisher) CanSchedule(at time.Time) bool {
+	lead := time.Until(at)
+	return lead >= minScheduleLead+scheduleMargin && lead <= maxScheduleLead
+}
+
+// Unschedule deletes every object of a natively scheduled post. If it fails after deleting some,
+// the error names the objects still scheduled.
+func (p *publisher) Unschedule(ctx context.Context, externalID string) error {
+	ids := strings.Split(externalID, ",")
+	for i, id := range ids {
+		if err := p.client.DeletePost(ctx, id); err != nil {
+			if i == 0 {
+				return err
+			}
+			return &connectors.UnscheduleError{Remaining: strings.Join(ids[i:], ","), Err: err}
+		}
+	}
+	return nil
+}
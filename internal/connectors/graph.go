@@ -0,0 +1,40 @@
+package connectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// GraphError reads a failed Graph API response (Facebook, Instagram, Threads) and classifies it
+// by HTTP status, refined by the Graph error code. what names the failed call in the message.
+func GraphError(platform string, resp *http.Response, what string) *Error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var ge struct {
+		Error struct {
+			Message     string `json:"message"`
+			Code        int    `json:"code"`
+			IsTransient bool   `json:"is_transient"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &ge)
+	msg := ge.Error.Message
+	if msg == "" {
+		msg = strings.TrimSpace(string(body))
+	}
+	e := StatusError(platform, resp, fmt.Errorf("%s %s status %d: %s", platform, what, resp.StatusCode, msg))
+	switch ge.Error.Code {
+	case 4, 17, 32, 613: // application/user/page/custom rate limits
+		e.Kind = KindRateLimited
+	case 190, 102: // expired or invalid access token
+		e.Kind = KindAuth
+	case 1, 2: // unknown / temporary service error
+		e.Kind = KindTransient
+	}
+	if ge.Error.IsTransient {
+		e.Kind = KindTransient
+	}
+	return e
+}
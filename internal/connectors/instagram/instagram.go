@@ -8,14 +8,40 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"trinity_bot/internal/connectors"
 )
 
 const graphHost = "https://graph.facebook.com/v19.0"
 
+// Carousel limits of the Instagram Graph API
+const (
+	minCarouselItems = 2
+	maxCarouselItems = 10
+)
+
+// Container status codes reported by GET /{container-id}?fields=status_code
+const (
+	statusFinished = "FINISHED"
+	statusError    = "ERROR"
+	statusExpired  = "EXPIRED"
+)
+
 type Client struct {
-	httpClient  *http.Client
-	accessToken string
+	httpClient   *http.Client
+	accessToken  string
+	baseURL      string
+	pollInterval time.Duration
+	readyTimeout time.Duration // how long to wait for a container to finish processing
+}
+
+// CarouselItem is an image or video of a carousel, given by a publicly accessible URL.
+type CarouselItem struct {
+	URL   string
+	Video bool
 }
 
 type Credentials struct {
@@ -24,9 +50,15 @@ type Credentials struct {
 
 func New(creds Credentials) (*Client, error) {
 	if creds.AccessToken == "" {
-		return nil, errors.New("instagram access token missing")
+		return nil, connectors.AuthError("instagram", "instagram access token missing")
 	}
-	return &Client{httpClient: &http.Client{Timeout: 25 * time.Second}, accessToken: creds.AccessToken}, nil
+	return &Client{
+		httpClient:   &http.Client{Timeout: 25 * time.Second},
+		accessToken:  creds.AccessToken,
+		baseURL:      graphHost,
+		pollInterval: 3 * time.Second,
+		readyTimeout: 5 * time.Minute,
+	}, nil
 }
 
 // CreatePhotoPost creates and publishes a photo post using a publicly accessible image URL.
@@ -41,71 +73,138 @@ func (c *Client) CreatePhotoPost(ctx context.Context, igUserID, caption, imageUR
 	if imageURL == "" {
 		return "", errors.New("image_url required for instagram")
 	}
-	// Step 1: create container
-	createVals := url.Values{}
-	createVals.Set("image_url", imageURL)
+	vals := url.Values{}
+	vals.Set("image_url", imageURL)
 	if caption != "" {
-		createVals.Set("caption", caption)
+		vals.Set("caption", caption)
+	}
+	return c.createAndPublish(ctx, igUserID, vals)
+}
+
+// ReelOptions are the optional settings of a Reel.
+type ReelOptions struct {
+	CoverURL    string // public URL of a cover image; takes precedence over ThumbOffset
+	ThumbOffset int    // milliseconds into the video used as cover frame
+	ShareToFeed bool   // also show the Reel in the profile feed
+}
+
+// CreateReel publishes a video as a Reel using a publicly accessible video URL. Instagram
+// processes the video before it can be published, which may take minutes.
+// Returns the published media id.
+func (c *Client) CreateReel(ctx context.Context, igUserID, caption, videoURL string, opts ReelOptions) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("instagram client not initialized")
+	}
+	if igUserID == "" {
+		return "", errors.New("instagram user id missing")
+	}
+	if videoURL == "" {
+		return "", errors.New("video_url required for instagram reels")
 	}
-	createVals.Set("access_token", c.accessToken)
-	createURL := fmt.Sprintf("%s/%s/media", graphHost, igUserID)
-	req1, err := http.NewRequestWithContext(ctx, http.MethodPost, createURL, bytes.NewBufferString(createVals.Encode()))
+	vals := url.Values{}
+	vals.Set("media_type", "REELS")
+	vals.Set("video_url", videoURL)
+	if caption != "" {
+		vals.Set("caption", caption)
+	}
+	if opts.CoverURL != "" {
+		vals.Set("cover_url", opts.CoverURL)
+	} else if opts.ThumbOffset > 0 {
+		vals.Set("thumb_offset", strconv.Itoa(opts.ThumbOffset))
+	}
+	vals.Set("share_to_feed", strconv.FormatBool(opts.ShareToFeed))
+	return c.createAndPublish(ctx, igUserID, vals)
+}
+
+// createAndPublish creates a container, waits until it is ready and publishes it.
+func (c *Client) createAndPublish(ctx context.Context, igUserID string, vals url.Values) (string, error) {
+	id, err := c.createContainer(ctx, igUserID, vals)
 	if err != nil {
 		return "", err
 	}
-	req1.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp1, err := c.httpClient.Do(req1)
-	if err != nil {
+	if err := c.waitContainer(ctx, id); err != nil {
 		return "", err
 	}
-	defer resp1.Body.Close()
-	if resp1.StatusCode < 200 || resp1.StatusCode >= 300 {
-		var apiErr struct {
-			Error struct {
-				Message string `json:"message"`
-			} `json:"error"`
-		}
-		_ = json.NewDecoder(resp1.Body).Decode(&apiErr)
-		return "", fmt.Errorf("instagram media create status %d: %s", resp1.StatusCode, apiErr.Error.Message)
+	return c.publishContainer(ctx, igUserID, id)
+}
+
+// CreateCarouselPost publishes 2-10 images and videos as one post: it creates a child container
+// per item, a CAROUSEL container referencing them, and publishes it once every container has
+// finished processing. Returns the published media id.
+func (c *Client) CreateCarouselPost(ctx context.Context, igUserID, caption string, items []CarouselItem) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("instagram client not initialized")
 	}
-	var created struct {
-		ID string `json:"id"`
+	if igUserID == "" {
+		return "", errors.New("instagram user id missing")
 	}
-	if err := json.NewDecoder(resp1.Body).Decode(&created); err != nil {
-		return "", err
+	if len(items) < minCarouselItems {
+		return "", connectors.ValidationError("instagram", fmt.Sprintf("instagram carousel needs at least %d items", minCarouselItems))
 	}
-	if created.ID == "" {
-		return "", errors.New("instagram: missing creation id")
+	if len(items) > maxCarouselItems {
+		items = items[:maxCarouselItems]
 	}
 
-	// Step 2: publish
-	pubVals := url.Values{}
-	pubVals.Set("creation_id", created.ID)
-	pubVals.Set("access_token", c.accessToken)
-	pubURL := fmt.Sprintf("%s/%s/media_publish", graphHost, igUserID)
-	req2, err := http.NewRequestWithContext(ctx, http.MethodPost, pubURL, bytes.NewBufferString(pubVals.Encode()))
+	children := make([]string, 0, len(items))
+	for _, it := range items {
+		vals := url.Values{}
+		vals.Set("is_carousel_item", "true")
+		if it.Video {
+			vals.Set("media_type", "VIDEO")
+			vals.Set("video_url", it.URL)
+		} else {
+			vals.Set("image_url", it.URL)
+		}
+		id, err := c.createContainer(ctx, igUserID, vals)
+		if err != nil {
+			return "", err
+		}
+		children = append(children, id)
+	}
+	for _, id := range children {
+		if err := c.waitContainer(ctx, id); err != nil {
+			return "", err
+		}
+	}
+
+	vals := url.Values{}
+	vals.Set("media_type", "CAROUSEL")
+	vals.Set("children", strings.Join(children, ","))
+	if caption != "" {
+		vals.Set("caption", caption)
+	}
+	parent, err := c.createContainer(ctx, igUserID, vals)
 	if err != nil {
 		return "", err
 	}
-	req2.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp2, err := c.httpClient.Do(req2)
-	if err != nil {
+	if err := c.waitContainer(ctx, parent); err != nil {
 		return "", err
 	}
-	defer resp2.Body.Close()
-	if resp2.StatusCode < 200 || resp2.StatusCode >= 300 {
-		var apiErr struct {
-			Error struct {
-				Message string `json:"message"`
-			} `json:"error"`
-		}
-		_ = json.NewDecoder(resp2.Body).Decode(&apiErr)
-		return "", fmt.Errorf("instagram media publish status %d: %s", resp2.StatusCode, apiErr.Error.Message)
+	return c.publishContainer(ctx, igUserID, parent)
+}
+
+// createContainer creates a media container and returns its creation id.
+func (c *Client) createContainer(ctx context.Context, igUserID string, vals url.Values) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := c.postForm(ctx, igUserID+"/media", vals, &created, "media create"); err != nil {
+		return "", err
+	}
+	if created.ID == "" {
+		return "", errors.New("instagram: missing creation id")
 	}
+	return created.ID, nil
+}
+
+// publishContainer publishes a processed container and returns the media id.
+func (c *Client) publishContainer(ctx context.Context, igUserID, creationID string) (string, error) {
+	vals := url.Values{}
+	vals.Set("creation_id", creationID)
 	var out struct {
 		ID string `json:"id"`
 	}
-	if err := json.NewDecoder(resp2.Body).Decode(&out); err != nil {
+	if err := c.postForm(ctx, igUserID+"/media_publish", vals, &out, "media publish"); err != nil {
 		return "", err
 	}
 	if out.ID == "" {
@@ -113,3 +212,54 @@ func (c *Client) CreatePhotoPost(ctx context.Context, igUserID, caption, imageUR
 	}
 	return out.ID, nil
 }
+
+// waitContainer polls a container's status_code until it is FINISHED. ERROR and EXPIRED
+// containers fail; a container still processing after readyTimeout is retried later.
+func (c *Client) waitContainer(ctx context.Context, containerID string) error {
+	return connectors.PollUntilReady(ctx, "instagram", c.pollInterval, c.readyTimeout, func(ctx context.Context) (bool, error) {
+		vals := url.Values{}
+		vals.Set("fields", "status_code,status")
+		vals.Set("access_token", c.accessToken)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s?%s", c.baseURL, containerID, vals.Encode()), nil)
+		if err != nil {
+			return false, err
+		}
+		var st struct {
+			StatusCode string `json:"status_code"`
+			Status     string `json:"status"`
+		}
+		if err := c.do(req, &st, "container status"); err != nil {
+			return false, err
+		}
+		switch st.StatusCode {
+		case statusFinished:
+			return true, nil
+		case statusError, statusExpired:
+			return false, connectors.ValidationError("instagram", fmt.Sprintf("instagram container %s: %s %s", containerID, st.StatusCode, st.Status))
+		}
+		return false, nil
+	})
+}
+
+// postForm sends a form-encoded POST to the Graph API and decodes the JSON response into out.
+func (c *Client) postForm(ctx context.Context, path string, vals url.Values, out any, what string) error {
+	vals.Set("access_token", c.accessToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+path, bytes.NewBufferString(vals.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, out, what)
+}
+
+func (c *Client) do(req *http.Request, out any, what string) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return connectors.NetworkError("instagram", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return connectors.GraphError("instagram", resp, what)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,94 @@
+package instagram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+const testToken = "IGQVJ-secret-token"
+
+func testClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+	c, err := New(Credentials{AccessToken: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = baseURL
+	c.pollInterval = time.Millisecond
+	c.readyTimeout = time.Second
+	return c
+}
+
+// Transport errors carry the request URL, and GET requests have the token in the query.
+func TestTransportErrorHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close() // every request fails with connection refused
+
+	c := testClient(t, srv.URL)
+	calls := map[string]func() error{
+		"container status": func() error { return c.waitContainer(context.Background(), "c-1") },
+		"media create": func() error {
+			_, err := c.CreatePhotoPost(context.Background(), "ig-1", "hi", "https://media.example.com/a.jpg")
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			err := call()
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if strings.Contains(err.Error(), testToken) || strings.Contains(err.Error(), srv.URL) {
+				t.Errorf("error leaks the request URL: %v", err)
+			}
+			if !connectors.Retryable(err) {
+				t.Errorf("transport error not retryable: %v", err)
+			}
+		})
+	}
+}
+
+func TestWaitContainer(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		wantErr  bool
+		kind     connectors.Kind
+	}{
+		{"finished", []string{"IN_PROGRESS", statusFinished}, false, 0},
+		{"error", []string{"IN_PROGRESS", statusError}, true, connectors.KindValidation},
+		{"expired", []string{statusExpired}, true, connectors.KindValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/c-1" || r.URL.Query().Get("access_token") != testToken {
+					t.Errorf("request = %s", r.URL)
+				}
+				st := tt.statuses[min(fetches, len(tt.statuses)-1)]
+				fetches++
+				_ = json.NewEncoder(w).Encode(map[string]string{"status_code": st})
+			}))
+			defer srv.Close()
+
+			err := testClient(t, srv.URL).waitContainer(context.Background(), "c-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && connectors.KindOf(err) != tt.kind {
+				t.Errorf("kind = %s, want %s", connectors.KindOf(err), tt.kind)
+			}
+			if fetches != len(tt.statuses) {
+				t.Errorf("status fetches = %d, want %d", fetches, len(tt.statuses))
+			}
+		})
+	}
+}
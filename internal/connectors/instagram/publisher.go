@@ -0,0 +1,66 @@
+package instagram
+
+import (
+	"context"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+func init() {
+	connectors.Register("instagram", newPublisher)
+}
+
+type publisher struct {
+	client *Client
+	userID string
+	reel   ReelOptions
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.InstagramAccessToken == "" || cfg.InstagramUserID == "" {
+		return nil, connectors.AuthError("instagram", "Instagram access token or user ID missing")
+	}
+	cli, err := New(Credentials{AccessToken: cfg.InstagramAccessToken})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{
+		client: cli,
+		userID: cfg.InstagramUserID,
+		reel:   ReelOptions{ShareToFeed: cfg.InstagramReelsShareToFeed, ThumbOffset: cfg.InstagramReelsThumbOffset},
+	}, nil
+}
+
+// Publish creates a carousel when the post has several attachments, a Reel when its only
+// attachment is a video, or a photo post from its first photo otherwise. Instagram pulls the media itself, so it needs public URLs rather
+// than the bytes.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	if len(post.Media) >= minCarouselItems {
+		items := make([]CarouselItem, 0, len(post.Media))
+		for _, m := range post.Media {
+			u, err := post.Files.URL(ctx, m.FileID)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, CarouselItem{URL: u, Video: m.Type == "video"})
+		}
+		return p.client.CreateCarouselPost(ctx, p.userID, post.Text, items)
+	}
+	if len(post.Media) == 1 && post.Media[0].Type == "video" {
+		videoURL, err := post.Files.URL(ctx, post.Media[0].FileID)
+		if err != nil {
+			return "", err
+		}
+		return p.client.CreateReel(ctx, p.userID, post.Text, videoURL, p.reel)
+	}
+	photos := post.Photos()
+	if len(photos) == 0 {
+		return "", connectors.ValidationError("instagram", "Instagram requires an image or video")
+	}
+	imgURL, err := post.Files.URL(ctx, photos[0].FileID)
+	if err != nil {
+		return "", err
+	}
+	return p.client.CreatePhotoPost(ctx, p.userID, post.Text, imgURL)
+}
@@ -0,0 +1,198 @@
+package linkedin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+const apiHost = "https://api.linkedin.com"
+
+type Client struct {
+	httpClient  *http.Client
+	accessToken string
+	baseURL     string
+}
+
+type Credentials struct {
+	AccessToken string
+}
+
+// Image is an image to attach to a post.
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+func New(creds Credentials) (*Client, error) {
+	if creds.AccessToken == "" {
+		return nil, connectors.AuthError("linkedin", "linkedin access token missing")
+	}
+	return &Client{
+		httpClient:  &http.Client{Timeout: 25 * time.Second},
+		accessToken: creds.AccessToken,
+		baseURL:     apiHost,
+	}, nil
+}
+
+// OrganizationURN normalizes an organization ID or URN to "urn:li:organization:<id>".
+func OrganizationURN(v string) string {
+	if strings.HasPrefix(v, "urn:li:") {
+		return v
+	}
+	return "urn:li:organization:" + v
+}
+
+// CreatePost uploads the images and shares them with the commentary as the organization.
+// Returns the share URN.
+func (c *Client) CreatePost(ctx context.Context, orgURN, commentary string, images []Image) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("linkedin client not initialized")
+	}
+	if orgURN == "" {
+		return "", errors.New("linkedin organization urn missing")
+	}
+
+	type media struct {
+		Status string `json:"status"`
+		Media  string `json:"media"`
+	}
+	var attached []media
+	for _, img := range images {
+		asset, err := c.UploadImage(ctx, orgURN, img.Data, img.ContentType)
+		if err != nil {
+			return "", err
+		}
+		attached = append(attached, media{Status: "READY", Media: asset})
+	}
+
+	share := map[string]any{
+		"shareCommentary":    map[string]string{"text": commentary},
+		"shareMediaCategory": "NONE",
+	}
+	if len(attached) > 0 {
+		share["shareMediaCategory"] = "IMAGE"
+		share["media"] = attached
+	}
+	payload := map[string]any{
+		"author":          orgURN,
+		"lifecycleState":  "PUBLISHED",
+		"specificContent": map[string]any{"com.linkedin.ugc.ShareContent": share},
+		"visibility":      map[string]string{"com.linkedin.ugc.MemberNetworkVisibility": "PUBLIC"},
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	resp, err := c.postJSON(ctx, "/v2/ugcPosts", payload, &out)
+	if err != nil {
+		return "", fmt.Errorf("linkedin create post: %w", err)
+	}
+	id := out.ID
+	if id == "" {
+		id = resp.Header.Get("X-RestLi-Id")
+	}
+	if id == "" {
+		return "", errors.New("linkedin create post: missing id in response")
+	}
+	return id, nil
+}
+
+// UploadImage registers an image upload owned by the organization and PUTs the binary.
+// Returns the digital media asset URN.
+func (c *Client) UploadImage(ctx context.Context, orgURN string, data []byte, contentType string) (string, error) {
+	register := map[string]any{
+		"registerUploadRequest": map[string]any{
+			"recipes": []string{"urn:li:digitalmediaRecipe:feedshare-image"},
+			"owner":   orgURN,
+			"serviceRelationships": []map[string]string{
+				{"relationshipType": "OWNER", "identifier": "urn:li:userGeneratedContent"},
+			},
+		},
+	}
+	var reg struct {
+		Value struct {
+			UploadMechanism struct {
+				Request struct {
+					UploadURL string `json:"uploadUrl"`
+				} `json:"com.linkedin.digitalmedia.uploading.MediaUploadHttpRequest"`
+			} `json:"uploadMechanism"`
+			Asset string `json:"asset"`
+		} `json:"value"`
+	}
+	if _, err := c.postJSON(ctx, "/v2/assets?action=registerUpload", register, &reg); err != nil {
+		return "", fmt.Errorf("linkedin register upload: %w", err)
+	}
+	uploadURL, asset := reg.Value.UploadMechanism.Request.UploadURL, reg.Value.Asset
+	if uploadURL == "" || asset == "" {
+		return "", errors.New("linkedin register upload: missing uploadUrl or asset")
+	}
+
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = "image/jpeg"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", connectors.NetworkError("linkedin", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("linkedin image upload: %w", apiError(resp))
+	}
+	return asset, nil
+}
+
+// postJSON POSTs a Rest.li request and decodes a 2xx JSON response into out.
+func (c *Client) postJSON(ctx context.Context, path string, in, out any) (*http.Response, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Restli-Protocol-Version", "2.0.0")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, connectors.NetworkError("linkedin", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, apiError(resp)
+	}
+	if out != nil {
+		// 201 Created responses may have an empty body
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var e struct {
+		Message string `json:"message"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		msg = e.Message
+	}
+	return connectors.StatusError("linkedin", resp, fmt.Errorf("status %d: %s", resp.StatusCode, msg))
+}
@@ -0,0 +1,51 @@
+package linkedin
+
+import (
+	"context"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+// maxImages is the number of images LinkedIn shows in a multi-image post.
+const maxImages = 9
+
+func init() {
+	connectors.Register("linkedin", newPublisher)
+}
+
+type publisher struct {
+	client *Client
+	org    string
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.LinkedInAccessToken == "" || cfg.LinkedInOrganization == "" {
+		return nil, connectors.AuthError("linkedin", "LinkedIn credentials missing")
+	}
+	cli, err := New(Credentials{AccessToken: cfg.LinkedInAccessToken})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{client: cli, org: OrganizationURN(cfg.LinkedInOrganization)}, nil
+}
+
+// Publish shares the text with up to 9 photos on the organization page.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	photos := post.Photos()
+	if len(photos) > maxImages {
+		photos = photos[:maxImages]
+	}
+	var images []Image
+	for _, m := range photos {
+		data, ctype, err := post.Files.Fetch(ctx, m.FileID)
+		if err != nil {
+			return "", err
+		}
+		images = append(images, Image{Data: data, ContentType: ctype})
+	}
+	if post.Text == "" && len(images) == 0 {
+		return "", connectors.ValidationError("linkedin", "LinkedIn post needs text or photos")
+	}
+	return p.client.CreatePost(ctx, p.org, post.Text, images)
+}
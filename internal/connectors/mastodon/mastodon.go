@@ -0,0 +1,202 @@
+package mastodon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+type Client struct {
+	httpClient   *http.Client
+	instanceURL  string
+	accessToken  string
+	pollInterval time.Duration
+}
+
+type Credentials struct {
+	InstanceURL string // e.g. https://mastodon.social
+	AccessToken string
+}
+
+// StatusOptions are the optional fields of a new status.
+type StatusOptions struct {
+	Visibility     string // public | unlisted | private | direct; instance default if empty
+	SpoilerText    string // content warning shown before the text
+	Language       string // ISO 639 code
+	IdempotencyKey string // repeated requests with the same key create the status only once
+}
+
+func New(creds Credentials) (*Client, error) {
+	if creds.InstanceURL == "" || creds.AccessToken == "" {
+		return nil, connectors.AuthError("mastodon", "mastodon instance url or access token missing")
+	}
+	u, err := url.Parse(creds.InstanceURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid mastodon instance url %q", creds.InstanceURL)
+	}
+	return &Client{
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+		instanceURL:  strings.TrimRight(creds.InstanceURL, "/"),
+		accessToken:  creds.AccessToken,
+		pollInterval: 2 * time.Second,
+	}, nil
+}
+
+// UploadMedia uploads an attachment with an optional description (alt text) and waits until the
+// instance has processed it. Returns the media ID to attach to a status.
+func (c *Client) UploadMedia(ctx context.Context, data []byte, contentType, description string) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("mastodon client not initialized")
+	}
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	filename := "file"
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		filename += exts[0]
+	}
+	fw, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return "", err
+	}
+	if description != "" {
+		_ = w.WriteField("description", description)
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.instanceURL+"/api/v2/media", &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	var att attachment
+	status, err := c.do(req, &att)
+	if err != nil {
+		return "", fmt.Errorf("mastodon media upload: %w", err)
+	}
+	if att.ID == "" {
+		return "", errors.New("mastodon media upload: missing id in response")
+	}
+	// 202 Accepted: large files (video, GIF) are processed asynchronously
+	if status == http.StatusAccepted || att.URL == nil {
+		if err := c.waitProcessed(ctx, att.ID); err != nil {
+			return "", err
+		}
+	}
+	return att.ID, nil
+}
+
+type attachment struct {
+	ID  string  `json:"id"`
+	URL *string `json:"url"` // null until processing finished
+}
+
+// waitProcessed polls an attachment until its processing is done or ctx expires.
+func (c *Client) waitProcessed(ctx context.Context, mediaID string) error {
+	return connectors.PollUntilReady(ctx, "mastodon", c.pollInterval, 0, func(ctx context.Context) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.instanceURL+"/api/v1/media/"+url.PathEscape(mediaID), nil)
+		if err != nil {
+			return false, err
+		}
+		var att attachment
+		status, err := c.do(req, &att)
+		if err != nil {
+			return false, fmt.Errorf("mastodon media status: %w", err)
+		}
+		// 206 Partial Content while processing, 200 with a URL once ready
+		return status == http.StatusOK && att.URL != nil, nil
+	})
+}
+
+// PostStatus publishes a status with the given attachments. Returns the status ID.
+func (c *Client) PostStatus(ctx context.Context, text string, mediaIDs []string, opts StatusOptions) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("mastodon client not initialized")
+	}
+	payload := struct {
+		Status      string   `json:"status,omitempty"`
+		MediaIDs    []string `json:"media_ids,omitempty"`
+		Visibility  string   `json:"visibility,omitempty"`
+		SpoilerText string   `json:"spoiler_text,omitempty"`
+		Language    string   `json:"language,omitempty"`
+	}{text, mediaIDs, opts.Visibility, opts.SpoilerText, opts.Language}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.instanceURL+"/api/v1/statuses", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.IdempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", opts.IdempotencyKey)
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if _, err := c.do(req, &out); err != nil {
+		return "", fmt.Errorf("mastodon post status: %w", err)
+	}
+	if out.ID == "" {
+		return "", errors.New("mastodon post status: missing id in response")
+	}
+	return out.ID, nil
+}
+
+// do sends an authorized request and decodes a 2xx JSON response into out.
+// Returns the response status code.
+func (c *Client) do(req *http.Request, out any) (int, error) {
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, connectors.NetworkError("mastodon", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, apiError(resp)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// apiError classifies an error response. Rate limits carry X-RateLimit-Reset (an ISO 8601 time)
+// instead of Retry-After.
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var e struct {
+		Error string `json:"error"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		msg = e.Error
+	}
+	ce := connectors.StatusError("mastodon", resp, fmt.Errorf("status %d: %s", resp.StatusCode, msg))
+	if ce.Kind == connectors.KindRateLimited && ce.RetryAfter == 0 {
+		if t, err := time.Parse(time.RFC3339, resp.Header.Get("X-RateLimit-Reset")); err == nil {
+			if d := time.Until(t); d > 0 {
+				ce.RetryAfter = d
+			}
+		}
+	}
+	return ce
+}
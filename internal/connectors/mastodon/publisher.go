@@ -0,0 +1,70 @@
+package mastodon
+
+import (
+	"context"
+	"fmt"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+// maxImages is the attachment limit of a status; a video can't be combined with other media.
+const maxImages = 4
+
+func init() {
+	connectors.Register("mastodon", newPublisher)
+}
+
+type publisher struct {
+	client *Client
+	opts   StatusOptions
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.MastodonInstanceURL == "" || cfg.MastodonAccessToken == "" {
+		return nil, connectors.AuthError("mastodon", "Mastodon credentials missing")
+	}
+	cli, err := New(Credentials{InstanceURL: cfg.MastodonInstanceURL, AccessToken: cfg.MastodonAccessToken})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{client: cli, opts: StatusOptions{
+		Visibility:  cfg.MastodonVisibility,
+		SpoilerText: cfg.MastodonSpoilerText,
+		Language:    cfg.MastodonLanguage,
+	}}, nil
+}
+
+// Publish posts the text with up to 4 photos, or with the first video if the post has no photos.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	media := post.Photos()
+	if len(media) > maxImages {
+		media = media[:maxImages]
+	}
+	if len(media) == 0 {
+		if videos := post.Videos(); len(videos) > 0 {
+			media = videos[:1]
+		}
+	}
+	if post.Text == "" && len(media) == 0 {
+		return "", connectors.ValidationError("mastodon", "Mastodon status needs text or media")
+	}
+
+	var ids []string
+	for _, m := range media {
+		data, ctype, err := post.Files.Fetch(ctx, m.FileID)
+		if err != nil {
+			return "", err
+		}
+		id, err := p.client.UploadMedia(ctx, data, ctype, m.Description)
+		if err != nil {
+			return "", err
+		}
+		ids = append(ids, id)
+	}
+
+	opts := p.opts
+	// Retries of the same post must not create a second status
+	opts.IdempotencyKey = fmt.Sprintf("trinity-post-%d", post.ID)
+	return p.client.PostStatus(ctx, post.Text, ids, opts)
+}
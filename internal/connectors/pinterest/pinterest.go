@@ -7,79 +7,358 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+const apiHost = "https://api.pinterest.com/v5"
+
+// pageSize is the largest page Pinterest returns for list endpoints.
+const pageSize = 250
+
+// Carousel pins hold 2 to 5 images.
+const (
+	minCarouselImages = 2
+	maxCarouselImages = 5
+)
+
+// Statuses of an uploaded media reported by GET /media/{media_id}
+const (
+	mediaSucceeded = "succeeded"
+	mediaFailed    = "failed"
 )
 
 type Client struct {
-	httpClient  *http.Client
-	accessToken string
+	httpClient   *http.Client
+	uploadClient *http.Client // for media uploads, bounded by the caller's context only
+	accessToken  string
+	baseURL      string
+	pollInterval time.Duration
+	readyTimeout time.Duration // how long to wait for an uploaded video to be processed
 }
 
 type Credentials struct {
 	AccessToken string
 }
 
+// Pin describes where and how a pin is created; the media is passed separately.
+type Pin struct {
+	BoardID        string
+	BoardSectionID string // optional
+	Title          string
+	Description    string
+	Link           string // destination URL, optional
+}
+
+// Image is an image of a carousel pin or a video pin's cover.
+type Image struct {
+	Data        []byte
+	ContentType string
+}
+
+// Board is a board of the authenticated user.
+type Board struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Section is a section of a board.
+type Section struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
 func New(creds Credentials) (*Client, error) {
 	if creds.AccessToken == "" {
-		return nil, errors.New("pinterest access token missing")
+		return nil, connectors.AuthError("pinterest", "pinterest access token missing")
 	}
 	return &Client{
-		httpClient:  &http.Client{Timeout: 25 * time.Second},
-		accessToken: creds.AccessToken,
+		httpClient:   &http.Client{Timeout: 25 * time.Second},
+		uploadClient: &http.Client{},
+		accessToken:  creds.AccessToken,
+		baseURL:      apiHost,
+		pollInterval: 5 * time.Second,
+		readyTimeout: 5 * time.Minute,
 	}, nil
 }
 
-// CreatePin creates a pin on the given board using base64 image.
+// CreatePin creates a pin on the pin's board (and section) using base64 image.
 // Returns the created pin ID.
-func (c *Client) CreatePin(ctx context.Context, boardID, title, description, link string, image []byte, contentType string) (string, error) {
-	if c == nil || c.httpClient == nil {
-		return "", errors.New("pinterest client not initialized")
+func (c *Client) CreatePin(ctx context.Context, pin Pin, image []byte, contentType string) (string, error) {
+	if len(image) == 0 {
+		return "", connectors.ValidationError("pinterest", "pinterest requires an image for a pin")
 	}
-	if boardID == "" {
-		return "", errors.New("pinterest board id missing")
+	return c.createPin(ctx, pin, map[string]any{
+		"source_type":  "image_base64",
+		"content_type": imageType(contentType),
+		"data":         base64.StdEncoding.EncodeToString(image),
+	})
+}
+
+// CreateCarouselPin creates a carousel pin from 2 to 5 images (multiple_image_base64); extra
+// images are dropped. Every item links to the pin's destination.
+// Returns the created pin ID.
+func (c *Client) CreateCarouselPin(ctx context.Context, pin Pin, images []Image) (string, error) {
+	if len(images) < minCarouselImages {
+		return "", connectors.ValidationError("pinterest", fmt.Sprintf("pinterest carousel needs at least %d images", minCarouselImages))
 	}
-	if len(image) == 0 {
-		return "", errors.New("pinterest requires an image for a pin")
+	if len(images) > maxCarouselImages {
+		images = images[:maxCarouselImages]
 	}
-	// Build payload
-	payload := struct {
+	type item struct {
 		Title       string `json:"title,omitempty"`
 		Description string `json:"description,omitempty"`
 		Link        string `json:"link,omitempty"`
-		BoardID     string `json:"board_id"`
-		MediaSource struct {
-			SourceType  string `json:"source_type"`
-			ContentType string `json:"content_type"`
-			Data        string `json:"data"`
-		} `json:"media_source"`
+		ContentType string `json:"content_type"`
+		Data        string `json:"data"`
+	}
+	items := make([]item, 0, len(images))
+	for _, img := range images {
+		items = append(items, item{
+			Title:       pin.Title,
+			Description: pin.Description,
+			Link:        pin.Link,
+			ContentType: imageType(img.ContentType),
+			Data:        base64.StdEncoding.EncodeToString(img.Data),
+		})
+	}
+	return c.createPin(ctx, pin, map[string]any{
+		"source_type": "multiple_image_base64",
+		"items":       items,
+		"index":       0,
+	})
+}
+
+// CreateVideoPin uploads a video through media registration, waits until Pinterest has processed
+// it and creates a video pin. The cover image is optional; without one the first frame is used.
+// Returns the created pin ID.
+func (c *Client) CreateVideoPin(ctx context.Context, pin Pin, video []byte, cover *Image) (string, error) {
+	if len(video) == 0 {
+		return "", connectors.ValidationError("pinterest", "pinterest video pin needs a video")
+	}
+	if pin.BoardID == "" {
+		return "", errors.New("pinterest board id missing")
+	}
+	mediaID, err := c.uploadVideo(ctx, video)
+	if err != nil {
+		return "", err
+	}
+	if err := c.waitMedia(ctx, mediaID); err != nil {
+		return "", err
+	}
+	source := map[string]any{
+		"source_type": "video_id",
+		"media_id":    mediaID,
+	}
+	if cover != nil && len(cover.Data) > 0 {
+		source["cover_image_content_type"] = imageType(cover.ContentType)
+		source["cover_image_data"] = base64.StdEncoding.EncodeToString(cover.Data)
+	} else {
+		source["cover_image_key_frame_time"] = 0
+	}
+	return c.createPin(ctx, pin, source)
+}
+
+// createPin creates a pin with the given media_source.
+func (c *Client) createPin(ctx context.Context, pin Pin, mediaSource map[string]any) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("pinterest client not initialized")
+	}
+	if pin.BoardID == "" {
+		return "", errors.New("pinterest board id missing")
+	}
+	payload := struct {
+		Title          string         `json:"title,omitempty"`
+		Description    string         `json:"description,omitempty"`
+		Link           string         `json:"link,omitempty"`
+		BoardID        string         `json:"board_id"`
+		BoardSectionID string         `json:"board_section_id,omitempty"`
+		MediaSource    map[string]any `json:"media_source"`
 	}{
-		Title:       title,
-		Description: description,
-		Link:        link,
-		BoardID:     boardID,
+		Title:          pin.Title,
+		Description:    pin.Description,
+		Link:           pin.Link,
+		BoardID:        pin.BoardID,
+		BoardSectionID: pin.BoardSectionID,
+		MediaSource:    mediaSource,
+	}
+	var out struct {
+		ID string `json:"id"`
 	}
-	if contentType == "" {
-		contentType = "image/jpeg"
+	if err := c.postJSON(ctx, "/pins", payload, &out, "create pin"); err != nil {
+		return "", err
+	}
+	if out.ID == "" {
+		return "", errors.New("pinterest: missing pin id in response")
 	}
-	payload.MediaSource.SourceType = "image_base64"
-	payload.MediaSource.ContentType = contentType
-	payload.MediaSource.Data = base64.StdEncoding.EncodeToString(image)
+	return out.ID, nil
+}
 
-	body, err := json.Marshal(payload)
+// uploadVideo registers a video upload and sends the file to the returned upload URL together
+// with the upload parameters Pinterest hands out. Returns the media ID.
+func (c *Client) uploadVideo(ctx context.Context, video []byte) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("pinterest client not initialized")
+	}
+	var reg struct {
+		MediaID          string            `json:"media_id"`
+		UploadURL        string            `json:"upload_url"`
+		UploadParameters map[string]string `json:"upload_parameters"`
+	}
+	if err := c.postJSON(ctx, "/media", map[string]string{"media_type": "video"}, &reg, "register media"); err != nil {
+		return "", err
+	}
+	if reg.MediaID == "" || reg.UploadURL == "" {
+		return "", errors.New("pinterest: missing media id or upload url in response")
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range reg.UploadParameters {
+		_ = mw.WriteField(k, v)
+	}
+	// The file must be the last field of the form
+	part, err := mw.CreateFormFile("file", "video.mp4")
 	if err != nil {
 		return "", err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.pinterest.com/v5/pins", bytes.NewReader(body))
+	if _, err := part.Write(video); err != nil {
+		return "", err
+	}
+	_ = mw.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reg.UploadURL, &buf)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := c.uploadClient.Do(req)
+	if err != nil {
+		return "", connectors.NetworkError("pinterest", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", connectors.StatusError("pinterest", resp, fmt.Errorf("pinterest media upload status %d: %s", resp.StatusCode, string(body)))
+	}
+	return reg.MediaID, nil
+}
+
+// waitMedia polls an uploaded media until Pinterest has processed it.
+func (c *Client) waitMedia(ctx context.Context, mediaID string) error {
+	return connectors.PollUntilReady(ctx, "pinterest", c.pollInterval, c.readyTimeout, func(ctx context.Context) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/media/"+url.PathEscape(mediaID), nil)
+		if err != nil {
+			return false, err
+		}
+		var st struct {
+			Status string `json:"status"`
+		}
+		if err := c.do(req, &st, "media status"); err != nil {
+			return false, err
+		}
+		switch st.Status {
+		case mediaSucceeded:
+			return true, nil
+		case mediaFailed:
+			return false, connectors.ValidationError("pinterest", fmt.Sprintf("pinterest media %s: processing failed", mediaID))
+		}
+		return false, nil
+	})
+}
+
+func imageType(contentType string) string {
+	if contentType == "" || contentType == "application/octet-stream" {
+		return "image/jpeg"
+	}
+	return contentType
+}
+
+// ListBoards returns all boards of the authenticated user.
+func (c *Client) ListBoards(ctx context.Context) ([]Board, error) {
+	var boards []Board
+	err := c.list(ctx, "/boards", "list boards", func(raw json.RawMessage) error {
+		var page []Board
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return err
+		}
+		boards = append(boards, page...)
+		return nil
+	})
+	return boards, err
+}
+
+// ListSections returns the sections of a board; boards without sections return none.
+func (c *Client) ListSections(ctx context.Context, boardID string) ([]Section, error) {
+	var sections []Section
+	err := c.list(ctx, "/boards/"+url.PathEscape(boardID)+"/sections", "list board sections", func(raw json.RawMessage) error {
+		var page []Section
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return err
+		}
+		sections = append(sections, page...)
+		return nil
+	})
+	return sections, err
+}
+
+// list follows the bookmark pagination of a list endpoint and hands each page's items to add.
+func (c *Client) list(ctx context.Context, path, what string, add func(items json.RawMessage) error) error {
+	if c == nil || c.httpClient == nil {
+		return errors.New("pinterest client not initialized")
+	}
+	bookmark := ""
+	for {
+		vals := url.Values{}
+		vals.Set("page_size", fmt.Sprint(pageSize))
+		if bookmark != "" {
+			vals.Set("bookmark", bookmark)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path+"?"+vals.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Items    json.RawMessage `json:"items"`
+			Bookmark string          `json:"bookmark"`
+		}
+		if err := c.do(req, &page, what); err != nil {
+			return err
+		}
+		if len(page.Items) > 0 {
+			if err := add(page.Items); err != nil {
+				return err
+			}
+		}
+		if page.Bookmark == "" {
+			return nil
+		}
+		bookmark = page.Bookmark
+	}
+}
+
+func (c *Client) postJSON(ctx context.Context, path string, payload, out any, what string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
 	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, out, what)
+}
 
+func (c *Client) do(req *http.Request, out any, what string) error {
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return connectors.NetworkError("pinterest", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
@@ -90,19 +369,7 @@ func (c *Client) CreatePin(ctx context.Context, boardID, title, description, lin
 		if apiErr.Message == "" {
 			apiErr.Message = resp.Status
 		}
-		return "", fmt.Errorf("pinterest create pin status %d: %s", resp.StatusCode, apiErr.Message)
-	}
-
-	var out struct {
-		ID string `json:"id"`
+		return connectors.StatusError("pinterest", resp, fmt.Errorf("pinterest %s status %d: %s", what, resp.StatusCode, apiErr.Message))
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return "", err
-	}
-	if out.ID == "" {
-		return "", errors.New("pinterest: missing pin id in response")
-	}
-	return out.ID, nil
+	return json.NewDecoder(resp.Body).Decode(out)
 }
-
-// no extra helpers
@@ -0,0 +1,86 @@
+package pinterest
+
+import (
+	"context"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+// maxTitleRunes is Pinterest's pin title limit.
+const maxTitleRunes = 100
+
+func init() {
+	connectors.Register("pinterest", newPublisher)
+}
+
+type publisher struct {
+	client  *Client
+	boardID string // default board for posts without a chosen board
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.PinterestAccessToken == "" {
+		return nil, connectors.AuthError("pinterest", "Pinterest token missing")
+	}
+	cli, err := New(Credentials{AccessToken: cfg.PinterestAccessToken})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{client: cli, boardID: cfg.PinterestBoardID}, nil
+}
+
+// Publish pins to the board (and section) chosen for the post, or to the configured board, using
+// the text as description and the post's link as destination. A post with a video becomes a
+// video pin with the first photo as cover, several photos a carousel pin, and a single photo an
+// image pin.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	pin := Pin{BoardID: post.PinterestBoardID, BoardSectionID: post.PinterestSectionID, Description: post.Text, Link: post.Link}
+	if pin.BoardID == "" {
+		pin.BoardID, pin.BoardSectionID = p.boardID, ""
+	}
+	if pin.BoardID == "" {
+		return "", connectors.ValidationError("pinterest", "No Pinterest board chosen for the post and PINTEREST_BOARD_ID not set")
+	}
+	// Pinterest recommends short title; use truncated text if present
+	title := []rune(post.Text)
+	if len(title) > maxTitleRunes {
+		title = title[:maxTitleRunes]
+	}
+	pin.Title = string(title)
+
+	photos := post.Photos()
+	if videos := post.Videos(); len(videos) > 0 {
+		video, _, err := post.Files.Fetch(ctx, videos[0].FileID)
+		if err != nil {
+			return "", err
+		}
+		var cover *Image
+		if len(photos) > 0 {
+			data, ctype, err := post.Files.Fetch(ctx, photos[0].FileID)
+			if err != nil {
+				return "", err
+			}
+			cover = &Image{Data: data, ContentType: ctype}
+		}
+		return p.client.CreateVideoPin(ctx, pin, video, cover)
+	}
+	if len(photos) == 0 {
+		return "", connectors.ValidationError("pinterest", "Pinterest requires an image or a video")
+	}
+	if len(photos) > maxCarouselImages {
+		photos = photos[:maxCarouselImages]
+	}
+	images := make([]Image, 0, len(photos))
+	for _, m := range photos {
+		data, ctype, err := post.Files.Fetch(ctx, m.FileID)
+		if err != nil {
+			return "", err
+		}
+		images = append(images, Image{Data: data, ContentType: ctype})
+	}
+	if len(images) >= minCarouselImages {
+		return p.client.CreateCarouselPin(ctx, pin, images)
+	}
+	return p.client.CreatePin(ctx, pin, images[0].Data, images[0].ContentType)
+}
@@ -0,0 +1,35 @@
+package connectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PollUntilReady calls check every interval until it reports done, returns an error, or timeout
+// elapses (0 means no limit besides ctx). check is called once right away. check reports a
+// failed state (e.g. a media container in ERROR) by returning an error, which is passed through.
+// Running out of time is reported as a transient error, so the publish is retried later.
+func PollUntilReady(ctx context.Context, platform string, interval, timeout time.Duration, check func(ctx context.Context) (bool, error)) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done, err := check(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return NetworkError(platform, fmt.Errorf("%s: still processing: %w", platform, ctx.Err()))
+		case <-ticker.C:
+		}
+	}
+}
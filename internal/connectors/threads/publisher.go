@@ -0,0 +1,45 @@
+package threads
+
+import (
+	"context"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+func init() {
+	connectors.Register("threads", newPublisher)
+}
+
+type publisher struct {
+	client *Client
+	userID string
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.ThreadsAccessToken == "" || cfg.ThreadsUserID == "" {
+		return nil, connectors.AuthError("threads", "Threads credentials missing")
+	}
+	cli, err := New(Credentials{AccessToken: cfg.ThreadsAccessToken})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{client: cli, userID: cfg.ThreadsUserID}, nil
+}
+
+// Publish posts the text alone, with a single attachment, or as a carousel of all attachments.
+// Threads fetches the media itself, so attachments are passed as public URLs.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	if post.Text == "" && len(post.Media) == 0 {
+		return "", connectors.ValidationError("threads", "Threads post needs text or media")
+	}
+	var items []Item
+	for _, m := range post.Media {
+		u, err := post.Files.URL(ctx, m.FileID)
+		if err != nil {
+			return "", err
+		}
+		items = append(items, Item{URL: u, Video: m.Type == "video"})
+	}
+	return p.client.CreatePost(ctx, p.userID, post.Text, items)
+}
@@ -0,0 +1,216 @@
+package threads
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+const graphHost = "https://graph.threads.net/v1.0"
+
+// Carousel limits of the Threads API
+const (
+	minCarouselItems = 2
+	maxCarouselItems = 20
+)
+
+// Container statuses reported by GET /{container-id}?fields=status
+const (
+	statusFinished = "FINISHED"
+	statusError    = "ERROR"
+	statusExpired  = "EXPIRED"
+)
+
+type Client struct {
+	httpClient   *http.Client
+	accessToken  string
+	baseURL      string
+	pollInterval time.Duration
+	readyTimeout time.Duration // how long to wait for a container to finish processing
+}
+
+type Credentials struct {
+	AccessToken string
+}
+
+// Item is an image or video of a post, given by a publicly accessible URL.
+type Item struct {
+	URL   string
+	Video bool
+}
+
+func New(creds Credentials) (*Client, error) {
+	if creds.AccessToken == "" {
+		return nil, connectors.AuthError("threads", "threads access token missing")
+	}
+	return &Client{
+		httpClient:   &http.Client{Timeout: 25 * time.Second},
+		accessToken:  creds.AccessToken,
+		baseURL:      graphHost,
+		pollInterval: 3 * time.Second,
+		readyTimeout: 5 * time.Minute,
+	}, nil
+}
+
+// CreatePost publishes a text post, a single image/video post or, for two or more items,
+// a carousel. Like Instagram it creates a container, waits until Threads has processed it and
+// then publishes it. Returns the published media id.
+func (c *Client) CreatePost(ctx context.Context, userID, text string, items []Item) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("threads client not initialized")
+	}
+	if userID == "" {
+		return "", errors.New("threads user id missing")
+	}
+	if len(items) > maxCarouselItems {
+		items = items[:maxCarouselItems]
+	}
+
+	var containerID string
+	var err error
+	switch {
+	case len(items) == 0:
+		vals := url.Values{}
+		vals.Set("media_type", "TEXT")
+		vals.Set("text", text)
+		containerID, err = c.createContainer(ctx, userID, vals)
+	case len(items) < minCarouselItems:
+		vals := itemValues(items[0])
+		if text != "" {
+			vals.Set("text", text)
+		}
+		containerID, err = c.createContainer(ctx, userID, vals)
+	default:
+		containerID, err = c.createCarousel(ctx, userID, text, items)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := c.waitFinished(ctx, containerID); err != nil {
+		return "", err
+	}
+
+	vals := url.Values{}
+	vals.Set("creation_id", containerID)
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := c.post(ctx, userID+"/threads_publish", vals, &out, "publish"); err != nil {
+		return "", err
+	}
+	if out.ID == "" {
+		return "", errors.New("threads: missing media id in response")
+	}
+	return out.ID, nil
+}
+
+// createCarousel creates a container per item and the CAROUSEL container referencing them.
+func (c *Client) createCarousel(ctx context.Context, userID, text string, items []Item) (string, error) {
+	children := make([]string, 0, len(items))
+	for _, it := range items {
+		vals := itemValues(it)
+		vals.Set("is_carousel_item", "true")
+		id, err := c.createContainer(ctx, userID, vals)
+		if err != nil {
+			return "", err
+		}
+		children = append(children, id)
+	}
+	// Children must be processed before the carousel container can be created
+	for _, id := range children {
+		if err := c.waitFinished(ctx, id); err != nil {
+			return "", err
+		}
+	}
+	vals := url.Values{}
+	vals.Set("media_type", "CAROUSEL")
+	vals.Set("children", strings.Join(children, ","))
+	if text != "" {
+		vals.Set("text", text)
+	}
+	return c.createContainer(ctx, userID, vals)
+}
+
+func itemValues(it Item) url.Values {
+	vals := url.Values{}
+	if it.Video {
+		vals.Set("media_type", "VIDEO")
+		vals.Set("video_url", it.URL)
+	} else {
+		vals.Set("media_type", "IMAGE")
+		vals.Set("image_url", it.URL)
+	}
+	return vals
+}
+
+func (c *Client) createContainer(ctx context.Context, userID string, vals url.Values) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := c.post(ctx, userID+"/threads", vals, &created, "container create"); err != nil {
+		return "", err
+	}
+	if created.ID == "" {
+		return "", errors.New("threads: missing creation id")
+	}
+	return created.ID, nil
+}
+
+// waitFinished polls a container until its status is FINISHED. ERROR and EXPIRED containers
+// fail; a container still processing after readyTimeout is retried later.
+func (c *Client) waitFinished(ctx context.Context, containerID string) error {
+	return connectors.PollUntilReady(ctx, "threads", c.pollInterval, c.readyTimeout, func(ctx context.Context) (bool, error) {
+		vals := url.Values{}
+		vals.Set("fields", "status,error_message")
+		vals.Set("access_token", c.accessToken)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/%s?%s", c.baseURL, containerID, vals.Encode()), nil)
+		if err != nil {
+			return false, err
+		}
+		var st struct {
+			Status       string `json:"status"`
+			ErrorMessage string `json:"error_message"`
+		}
+		if err := c.do(req, &st, "container status"); err != nil {
+			return false, err
+		}
+		switch st.Status {
+		case statusFinished:
+			return true, nil
+		case statusError, statusExpired:
+			return false, connectors.ValidationError("threads", fmt.Sprintf("threads container %s: %s %s", containerID, st.Status, st.ErrorMessage))
+		}
+		return false, nil
+	})
+}
+
+// post sends a form-encoded POST to the Threads API and decodes the JSON response into out.
+func (c *Client) post(ctx context.Context, path string, vals url.Values, out any, what string) error {
+	vals.Set("access_token", c.accessToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+path, bytes.NewBufferString(vals.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, out, what)
+}
+
+func (c *Client) do(req *http.Request, out any, what string) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return connectors.NetworkError("threads", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return connectors.GraphError("threads", resp, what)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
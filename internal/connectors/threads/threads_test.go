@@ -0,0 +1,77 @@
+package threads
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+const testToken = "THQVJ-secret-token"
+
+func testClient(t *testing.T, baseURL string) *Client {
+	t.Helper()
+	c, err := New(Credentials{AccessToken: testToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.baseURL = baseURL
+	c.pollInterval = time.Millisecond
+	c.readyTimeout = 50 * time.Millisecond
+	return c
+}
+
+// Transport errors carry the request URL, and status requests have the token in the query.
+func TestTransportErrorHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close() // every request fails with connection refused
+
+	err := testClient(t, srv.URL).waitFinished(context.Background(), "c-1")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), testToken) || strings.Contains(err.Error(), srv.URL) {
+		t.Errorf("error leaks the request URL: %v", err)
+	}
+	if !connectors.Retryable(err) {
+		t.Errorf("transport error not retryable: %v", err)
+	}
+}
+
+func TestWaitFinished(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		wantErr   bool
+		retryable bool
+	}{
+		{"finished", statusFinished, false, false},
+		{"error", statusError, true, false},
+		{"still processing", "IN_PROGRESS", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/c-1" || r.URL.Query().Get("access_token") != testToken {
+					t.Errorf("request = %s", r.URL)
+				}
+				_ = json.NewEncoder(w).Encode(map[string]string{"status": tt.status, "error_message": "bad media"})
+			}))
+			defer srv.Close()
+
+			// readyTimeout, not the caller's context, ends the wait
+			err := testClient(t, srv.URL).waitFinished(context.Background(), "c-1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && connectors.Retryable(err) != tt.retryable {
+				t.Errorf("retryable = %v, want %v: %v", !tt.retryable, tt.retryable, err)
+			}
+		})
+	}
+}
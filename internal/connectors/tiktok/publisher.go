@@ -0,0 +1,58 @@
+package tiktok
+
+import (
+	"context"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+// maxTitleRunes is TikTok's caption limit.
+const maxTitleRunes = 2200
+
+func init() {
+	connectors.Register("tiktok", newPublisher)
+}
+
+type publisher struct {
+	client   *Client
+	privacy  string
+	pullMode bool // let TikTok pull the video from its public media URL (TIKTOK_PULL_FROM_URL)
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.TikTokAccessToken == "" {
+		return nil, connectors.AuthError("tiktok", "TikTok access token missing")
+	}
+	cli, err := New(Credentials{AccessToken: cfg.TikTokAccessToken})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{client: cli, privacy: cfg.TikTokPrivacyLevel, pullMode: cfg.TikTokPullFromURL}, nil
+}
+
+// Publish uploads the post's first video with the text as caption, or in pull mode hands TikTok
+// the video's public media URL.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	videos := post.Videos()
+	if len(videos) == 0 {
+		return "", connectors.ValidationError("tiktok", "TikTok requires a video")
+	}
+	title := []rune(post.Text)
+	if len(title) > maxTitleRunes {
+		title = title[:maxTitleRunes]
+	}
+	info := PostInfo{Title: string(title), PrivacyLevel: p.privacy}
+	if p.pullMode {
+		videoURL, err := post.Files.URL(ctx, videos[0].FileID)
+		if err != nil {
+			return "", err
+		}
+		return p.client.PublishVideoFromURL(ctx, info, videoURL)
+	}
+	data, ctype, err := post.Files.Fetch(ctx, videos[0].FileID)
+	if err != nil {
+		return "", err
+	}
+	return p.client.PublishVideo(ctx, info, data, ctype)
+}
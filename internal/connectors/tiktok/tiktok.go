@@ -0,0 +1,266 @@
+package tiktok
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+const apiHost = "https://open.tiktokapis.com"
+
+// chunkSize is the upload chunk size. The Content Posting API takes 5-64 MB chunks, lets the last
+// chunk absorb the remainder and accepts smaller videos as a single chunk.
+const chunkSize = 10 << 20
+
+// Publish statuses reported by /v2/post/publish/status/fetch/
+const (
+	statusComplete = "PUBLISH_COMPLETE"
+	statusInbox    = "SEND_TO_USER_INBOX"
+	statusFailed   = "FAILED"
+)
+
+type Client struct {
+	httpClient   *http.Client
+	accessToken  string
+	baseURL      string
+	pollInterval time.Duration
+}
+
+type Credentials struct {
+	AccessToken string
+}
+
+// PostInfo describes how the video appears on TikTok.
+type PostInfo struct {
+	Title          string `json:"title,omitempty"`
+	PrivacyLevel   string `json:"privacy_level"` // e.g. SELF_ONLY (required for unaudited apps), PUBLIC_TO_EVERYONE
+	DisableComment bool   `json:"disable_comment,omitempty"`
+	DisableDuet    bool   `json:"disable_duet,omitempty"`
+	DisableStitch  bool   `json:"disable_stitch,omitempty"`
+}
+
+func New(creds Credentials) (*Client, error) {
+	if creds.AccessToken == "" {
+		return nil, connectors.AuthError("tiktok", "tiktok access token missing")
+	}
+	return &Client{
+		httpClient:   &http.Client{Timeout: 60 * time.Second},
+		accessToken:  creds.AccessToken,
+		baseURL:      apiHost,
+		pollInterval: 5 * time.Second,
+	}, nil
+}
+
+// sourceInfo tells TikTok where the video comes from: FILE_UPLOAD (chunks pushed by the bot) or
+// PULL_FROM_URL (TikTok downloads it from a URL on a domain verified for the app).
+type sourceInfo struct {
+	Source          string `json:"source"`
+	VideoSize       int64  `json:"video_size,omitempty"`
+	ChunkSize       int64  `json:"chunk_size,omitempty"`
+	TotalChunkCount int    `json:"total_chunk_count,omitempty"`
+	VideoURL        string `json:"video_url,omitempty"`
+}
+
+// initPost initializes a direct post and returns its publish ID and, for FILE_UPLOAD, the upload URL.
+func (c *Client) initPost(ctx context.Context, info PostInfo, source sourceInfo) (publishID, uploadURL string, err error) {
+	initReq := struct {
+		PostInfo   PostInfo   `json:"post_info"`
+		SourceInfo sourceInfo `json:"source_info"`
+	}{info, source}
+	var initResp struct {
+		PublishID string `json:"publish_id"`
+		UploadURL string `json:"upload_url"`
+	}
+	if err := c.call(ctx, "/v2/post/publish/video/init/", initReq, &initResp); err != nil {
+		return "", "", err
+	}
+	if initResp.PublishID == "" {
+		return "", "", errors.New("tiktok: missing publish_id in init response")
+	}
+	return initResp.PublishID, initResp.UploadURL, nil
+}
+
+// PublishVideoFromURL lets TikTok pull the video from a public URL, whose domain must be verified
+// for the app, and polls until TikTok reports the post as published. Returns the publish ID.
+func (c *Client) PublishVideoFromURL(ctx context.Context, info PostInfo, videoURL string) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("tiktok client not initialized")
+	}
+	if videoURL == "" {
+		return "", connectors.ValidationError("tiktok", "tiktok requires a video")
+	}
+	publishID, _, err := c.initPost(ctx, info, sourceInfo{Source: "PULL_FROM_URL", VideoURL: videoURL})
+	if err != nil {
+		return "", err
+	}
+	if err := c.waitPublished(ctx, publishID); err != nil {
+		return "", err
+	}
+	return publishID, nil
+}
+
+// PublishVideo uploads a video with the Content Posting API: it initializes a direct post,
+// pushes the file in chunks and polls until TikTok reports the post as published.
+// Returns the publish ID.
+func (c *Client) PublishVideo(ctx context.Context, info PostInfo, video []byte, contentType string) (string, error) {
+	if c == nil || c.httpClient == nil {
+		return "", errors.New("tiktok client not initialized")
+	}
+	if len(video) == 0 {
+		return "", connectors.ValidationError("tiktok", "tiktok requires a video")
+	}
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = "video/mp4"
+	}
+
+	size := int64(len(video))
+	chunk, count := chunkPlan(size)
+	publishID, uploadURL, err := c.initPost(ctx, info, sourceInfo{
+		Source:          "FILE_UPLOAD",
+		VideoSize:       size,
+		ChunkSize:       chunk,
+		TotalChunkCount: count,
+	})
+	if err != nil {
+		return "", err
+	}
+	if uploadURL == "" {
+		return "", errors.New("tiktok: missing upload_url in init response")
+	}
+
+	for i := 0; i < count; i++ {
+		first := int64(i) * chunk
+		last := first + chunk - 1
+		if i == count-1 {
+			last = size - 1
+		}
+		if err := c.uploadChunk(ctx, uploadURL, video[first:last+1], first, last, size, contentType); err != nil {
+			return "", err
+		}
+	}
+
+	if err := c.waitPublished(ctx, publishID); err != nil {
+		return "", err
+	}
+	return publishID, nil
+}
+
+// chunkPlan splits a video into upload chunks. Videos up to one chunk are sent whole;
+// otherwise the last chunk takes the remainder, as the API requires.
+func chunkPlan(size int64) (chunk int64, count int) {
+	if size <= chunkSize {
+		return size, 1
+	}
+	return chunkSize, int(size / chunkSize)
+}
+
+func (c *Client) uploadChunk(ctx context.Context, uploadURL string, data []byte, first, last, total int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, total))
+	req.ContentLength = int64(len(data))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return connectors.NetworkError("tiktok", err)
+	}
+	defer resp.Body.Close()
+	// 206 Partial Content for intermediate chunks, 201 Created once the upload is complete
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return connectors.StatusError("tiktok", resp, fmt.Errorf("tiktok chunk upload status %d: %s", resp.StatusCode, string(body)))
+	}
+	return nil
+}
+
+// waitPublished polls the publish status until the post is complete, failed or ctx expires.
+// Once initialized, a direct post is published by TikTok whether or not the bot keeps polling,
+// so running out of time (or losing the status endpoint) is not retried: publishing again would
+// create a second post. Only an explicit internal failure reported by TikTok is retryable.
+func (c *Client) waitPublished(ctx context.Context, publishID string) error {
+	failed := false
+	err := connectors.PollUntilReady(ctx, "tiktok", c.pollInterval, 0, func(ctx context.Context) (bool, error) {
+		var status struct {
+			Status     string `json:"status"`
+			FailReason string `json:"fail_reason"`
+		}
+		if err := c.call(ctx, "/v2/post/publish/status/fetch/", map[string]string{"publish_id": publishID}, &status); err != nil {
+			return false, err
+		}
+		switch status.Status {
+		case statusComplete, statusInbox:
+			return true, nil
+		case statusFailed:
+			failed = true
+			msg := fmt.Sprintf("tiktok publish failed: %s", status.FailReason)
+			if status.FailReason == "internal" {
+				return false, connectors.NetworkError("tiktok", errors.New(msg))
+			}
+			return false, connectors.ValidationError("tiktok", msg)
+		}
+		return false, nil
+	})
+	if err != nil && !failed && connectors.Retryable(err) {
+		return &connectors.Error{Platform: "tiktok", Kind: connectors.KindUnknown,
+			Err: fmt.Errorf("tiktok publish %s not confirmed, check TikTok before publishing again: %w", publishID, err)}
+	}
+	return err
+}
+
+// call POSTs a JSON request to the API and decodes the "data" member of the response into out.
+func (c *Client) call(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return connectors.NetworkError("tiktok", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data  json.RawMessage `json:"data"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&envelope)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || (envelope.Error.Code != "" && envelope.Error.Code != "ok") {
+		e := connectors.StatusError("tiktok", resp, fmt.Errorf("tiktok %s status %d: %s %s", path, resp.StatusCode, envelope.Error.Code, envelope.Error.Message))
+		switch envelope.Error.Code {
+		case "access_token_invalid", "scope_not_authorized", "token_not_authorized_for_specified_user":
+			e.Kind = connectors.KindAuth
+		case "rate_limit_exceeded":
+			e.Kind = connectors.KindRateLimited
+		default:
+			if e.Kind == connectors.KindUnknown {
+				e.Kind = connectors.KindValidation
+			}
+		}
+		return e
+	}
+	if decodeErr != nil {
+		return decodeErr
+	}
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
@@ -0,0 +1,226 @@
+package tiktok
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trinity_bot/internal/connectors"
+)
+
+// fakeTikTok is a local stand-in for the Content Posting API. statuses are returned by successive
+// status fetches; the last one repeats.
+type fakeTikTok struct {
+	t        *testing.T
+	srv      *httptest.Server
+	statuses []map[string]string
+
+	mu      sync.Mutex
+	inits   []map[string]any
+	ranges  []string
+	ctypes  []string
+	uploads int64
+	fetches int
+}
+
+func newFakeTikTok(t *testing.T, statuses ...map[string]string) *fakeTikTok {
+	f := &fakeTikTok{t: t, statuses: statuses}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/post/publish/video/init/", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode init: %v", err)
+		}
+		f.mu.Lock()
+		f.inits = append(f.inits, body)
+		f.mu.Unlock()
+		writeData(w, map[string]string{"publish_id": "pub-1", "upload_url": f.srv.URL + "/upload"})
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("upload method = %s", r.Method)
+		}
+		n, _ := io.Copy(io.Discard, r.Body)
+		f.mu.Lock()
+		f.ranges = append(f.ranges, r.Header.Get("Content-Range"))
+		f.ctypes = append(f.ctypes, r.Header.Get("Content-Type"))
+		f.uploads += n
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusPartialContent)
+	})
+	mux.HandleFunc("/v2/post/publish/status/fetch/", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		i := min(f.fetches, len(f.statuses)-1)
+		f.fetches++
+		f.mu.Unlock()
+		writeData(w, f.statuses[i])
+	})
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func writeData(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data, "error": map[string]string{"code": "ok"}})
+}
+
+func (f *fakeTikTok) client() *Client {
+	c, err := New(Credentials{AccessToken: "tok"})
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	c.baseURL = f.srv.URL
+	c.pollInterval = time.Millisecond
+	return c
+}
+
+func status(s, reason string) map[string]string {
+	return map[string]string{"status": s, "fail_reason": reason}
+}
+
+func TestPublishVideoChunks(t *testing.T) {
+	f := newFakeTikTok(t, status("PROCESSING_UPLOAD", ""), status(statusComplete, ""))
+	size := 2*chunkSize + 123 // two chunks, the last one absorbing the remainder
+	video := make([]byte, size)
+
+	id, err := f.client().PublishVideo(context.Background(), PostInfo{Title: "hi", PrivacyLevel: "SELF_ONLY"}, video, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "pub-1" {
+		t.Errorf("publish id = %q", id)
+	}
+
+	if len(f.inits) != 1 {
+		t.Fatalf("init calls = %d", len(f.inits))
+	}
+	src := f.inits[0]["source_info"].(map[string]any)
+	if src["source"] != "FILE_UPLOAD" || src["video_size"] != float64(size) ||
+		src["chunk_size"] != float64(chunkSize) || src["total_chunk_count"] != float64(2) {
+		t.Errorf("source_info = %v", src)
+	}
+	if _, ok := src["video_url"]; ok {
+		t.Error("FILE_UPLOAD init sends video_url")
+	}
+	post := f.inits[0]["post_info"].(map[string]any)
+	if post["title"] != "hi" || post["privacy_level"] != "SELF_ONLY" {
+		t.Errorf("post_info = %v", post)
+	}
+
+	want := []string{
+		fmt.Sprintf("bytes 0-%d/%d", chunkSize-1, size),
+		fmt.Sprintf("bytes %d-%d/%d", chunkSize, size-1, size),
+	}
+	if strings.Join(f.ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("Content-Range = %q, want %q", f.ranges, want)
+	}
+	if f.uploads != int64(size) {
+		t.Errorf("uploaded %d bytes, want %d", f.uploads, size)
+	}
+	for _, ct := range f.ctypes {
+		if ct != "video/mp4" {
+			t.Errorf("chunk Content-Type = %q", ct)
+		}
+	}
+	if f.fetches != 2 {
+		t.Errorf("status fetches = %d, want 2", f.fetches)
+	}
+}
+
+func TestChunkPlan(t *testing.T) {
+	tests := []struct {
+		size  int64
+		chunk int64
+		count int
+	}{
+		{1, 1, 1},
+		{chunkSize, chunkSize, 1},
+		{chunkSize + 1, chunkSize, 1},
+		{2 * chunkSize, chunkSize, 2},
+		{3*chunkSize - 1, chunkSize, 2},
+	}
+	for _, tt := range tests {
+		chunk, count := chunkPlan(tt.size)
+		if chunk != tt.chunk || count != tt.count {
+			t.Errorf("chunkPlan(%d) = %d, %d; want %d, %d", tt.size, chunk, count, tt.chunk, tt.count)
+		}
+	}
+}
+
+func TestPublishVideoFromURL(t *testing.T) {
+	f := newFakeTikTok(t, status(statusInbox, ""))
+	_, err := f.client().PublishVideoFromURL(context.Background(), PostInfo{PrivacyLevel: "SELF_ONLY"}, "https://media.example.com/media/abc?sig=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := f.inits[0]["source_info"].(map[string]any)
+	if src["source"] != "PULL_FROM_URL" || src["video_url"] != "https://media.example.com/media/abc?sig=x" {
+		t.Errorf("source_info = %v", src)
+	}
+	if _, ok := src["video_size"]; ok {
+		t.Error("PULL_FROM_URL init sends video_size")
+	}
+	if len(f.ranges) != 0 {
+		t.Errorf("pull mode uploaded %d chunks", len(f.ranges))
+	}
+}
+
+func TestWaitPublishedFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    map[string]string
+		kind      connectors.Kind
+		retryable bool
+	}{
+		{"failed", status(statusFailed, "video_pull_failed"), connectors.KindValidation, false},
+		{"internal", status(statusFailed, "internal"), connectors.KindTransient, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeTikTok(t, tt.status)
+			err := f.client().waitPublished(context.Background(), "pub-1")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if k := connectors.KindOf(err); k != tt.kind {
+				t.Errorf("kind = %s, want %s", k, tt.kind)
+			}
+			if connectors.Retryable(err) != tt.retryable {
+				t.Errorf("retryable = %v, want %v", !tt.retryable, tt.retryable)
+			}
+		})
+	}
+}
+
+// A post still processing when the publish timeout runs out must not be retried: the retry
+// would initialize a second post.
+func TestWaitPublishedTimeoutNotRetried(t *testing.T) {
+	f := newFakeTikTok(t, status("PROCESSING_UPLOAD", ""))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := f.client().waitPublished(ctx, "pub-1")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if connectors.Retryable(err) {
+		t.Errorf("timeout after init is retryable: %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error does not wrap the deadline: %v", err)
+	}
+	if !strings.Contains(err.Error(), "pub-1") {
+		t.Errorf("error does not name the publish id: %v", err)
+	}
+}
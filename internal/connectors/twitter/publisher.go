@@ -0,0 +1,87 @@
+package twitter
+
+import (
+	"context"
+	"strings"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/connectors"
+)
+
+func init() {
+	connectors.Register("twitter", newPublisher)
+}
+
+type publisher struct {
+	client *Client
+}
+
+func newPublisher(cfg *config.Config) (connectors.Publisher, error) {
+	if cfg.TwitterOAuth2Token != "" {
+		cli, err := New(Credentials{OAuth2Token: cfg.TwitterOAuth2Token})
+		if err != nil {
+			return nil, err
+		}
+		return &publisher{client: cli}, nil
+	}
+	if cfg.TwitterConsumerKey == "" || cfg.TwitterConsumerSecret == "" || cfg.TwitterAccessToken == "" || cfg.TwitterAccessSecret == "" {
+		return nil, connectors.AuthError("twitter", "Twitter credentials missing")
+	}
+	cli, err := New(Credentials{
+		ConsumerKey:    cfg.TwitterConsumerKey,
+		ConsumerSecret: cfg.TwitterConsumerSecret,
+		AccessToken:    cfg.TwitterAccessToken,
+		AccessSecret:   cfg.TwitterAccessSecret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &publisher{client: cli}, nil
+}
+
+// Publish tweets the post text with its media. A tweet holds either up to 4 images or one
+// video/GIF, so the first attachment decides: a leading video is tweeted alone, otherwise the
+// photos among the first 4 attachments are used. Photos that cannot be downloaded are skipped.
+func (p *publisher) Publish(ctx context.Context, post *connectors.Post) (string, error) {
+	var media [][]byte
+	var mediaTypes []string
+	if len(post.Media) > 0 && strings.EqualFold(post.Media[0].Type, "video") {
+		data, ctype, err := post.Files.Fetch(ctx, post.Media[0].FileID)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(ctype, "video/") && ctype != "image/gif" {
+			ctype = "video/mp4"
+		}
+		return p.tweet(ctx, post.Text, [][]byte{data}, []string{ctype})
+	}
+	items := post.Media
+	if len(items) > 4 {
+		items = items[:4]
+	}
+	for _, m := range items {
+		if !strings.EqualFold(m.Type, "photo") {
+			continue
+		}
+		data, ctype, err := post.Files.Fetch(ctx, m.FileID)
+		if err != nil {
+			continue
+		}
+		if !strings.HasPrefix(ctype, "image/") {
+			ctype = "image/jpeg"
+		}
+		media = append(media, data)
+		mediaTypes = append(mediaTypes, ctype)
+	}
+	return p.tweet(ctx, post.Text, media, mediaTypes)
+}
+
+// tweet posts the text, as a numbered thread if it is longer than a tweet. The external ID is
+// the comma-separated list of tweet IDs, the first being the head of the thread.
+func (p *publisher) tweet(ctx context.Context, text string, media [][]byte, mediaTypes []string) (string, error) {
+	ids, err := p.client.Thread(ctx, splitThread(text), media, mediaTypes)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(ids, ","), nil
+}
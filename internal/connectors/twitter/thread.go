@@ -0,0 +1,175 @@
+package twitter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Tweet length rules of twitter-text v3: a tweet holds 280 weighted characters, every URL
+// counts as 23 and code points outside the Latin/punctuation ranges below (CJK, emoji, ...)
+// count as 2.
+const (
+	maxTweetWeight = 280
+	urlWeight      = 23
+)
+
+var urlRe = regexp.MustCompile(`https?://[^\s]+`)
+
+// lightRanges are the code point ranges weighted 1; everything else weighs 2.
+var lightRanges = [][2]rune{{0, 4351}, {8192, 8205}, {8208, 8223}, {8242, 8247}}
+
+func runeWeight(r rune) int {
+	for _, rg := range lightRanges {
+		if r >= rg[0] && r <= rg[1] {
+			return 1
+		}
+	}
+	return 2
+}
+
+// weightedLength returns the length of text as Twitter counts it.
+func weightedLength(text string) int {
+	n := 0
+	last := 0
+	for _, loc := range urlRe.FindAllStringIndex(text, -1) {
+		for _, r := range text[last:loc[0]] {
+			n += runeWeight(r)
+		}
+		n += urlWeight
+		last = loc[1]
+	}
+	for _, r := range text[last:] {
+		n += runeWeight(r)
+	}
+	return n
+}
+
+// splitThread splits text into tweets of at most 280 weighted characters. Text that fits is
+// returned as is; otherwise the parts are numbered ("… 1/3") and broken at sentence
+// boundaries, then at word boundaries, and only words longer than a tweet are cut.
+func splitThread(text string) []string {
+	text = strings.TrimSpace(text)
+	if weightedLength(text) <= maxTweetWeight {
+		return []string{text}
+	}
+	// The suffix length depends on the number of parts; retry until the digit count is stable.
+	total := 9
+	for {
+		suffix := fmt.Sprintf(" %d/%d", total, total)
+		parts := packText(text, maxTweetWeight-weightedLength(suffix))
+		if len(fmt.Sprint(len(parts))) <= len(fmt.Sprint(total)) {
+			for i := range parts {
+				parts[i] = fmt.Sprintf("%s %d/%d", parts[i], i+1, len(parts))
+			}
+			return parts
+		}
+		total = total*10 + 9
+	}
+}
+
+// packText greedily fills chunks of at most limit weighted characters with whole sentences,
+// falling back to words and, for overlong words, to cutting the word.
+func packText(text string, limit int) []string {
+	var chunks []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		cur.Reset()
+	}
+	// add appends a piece (with its trailing whitespace) if it fits, starting a new chunk if needed.
+	add := func(piece string) bool {
+		if weightedLength(strings.TrimSpace(cur.String()+piece)) <= limit {
+			cur.WriteString(piece)
+			return true
+		}
+		flush()
+		if weightedLength(strings.TrimSpace(piece)) <= limit {
+			cur.WriteString(piece)
+			return true
+		}
+		return false
+	}
+
+	for _, sentence := range splitKeepingSpace(text, isSentenceEnd) {
+		if add(sentence) {
+			continue
+		}
+		for _, word := range splitKeepingSpace(sentence, nil) {
+			if add(word) {
+				continue
+			}
+			for _, piece := range cutWord(strings.TrimSpace(word), limit) {
+				flush()
+				cur.WriteString(piece + " ")
+			}
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitKeepingSpace splits text after whitespace runs, keeping the whitespace with the piece
+// before it. With a non-nil boundary only whitespace following a rune for which boundary
+// returns true, a newline or a CJK full stop ends a piece (sentences); with nil every
+// whitespace run does (words).
+func splitKeepingSpace(text string, boundary func(prev rune) bool) []string {
+	var out []string
+	start := 0
+	var prev rune
+	inSpace := false
+	for i, r := range text {
+		isSpace := r == ' ' || r == '\n' || r == '\t' || r == '\r'
+		if inSpace && !isSpace {
+			out = append(out, text[start:i])
+			start = i
+			inSpace = false
+		}
+		if isSpace && !inSpace && (boundary == nil || boundary(prev) || r == '\n') {
+			inSpace = true
+		}
+		// CJK full stops end a sentence without a following space
+		if boundary != nil && isFullWidthEnd(r) {
+			inSpace = true
+		}
+		if !isSpace {
+			prev = r
+		}
+	}
+	if start < len(text) {
+		out = append(out, text[start:])
+	}
+	return out
+}
+
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '.', '!', '?', '…':
+		return true
+	}
+	return isFullWidthEnd(r)
+}
+
+func isFullWidthEnd(r rune) bool {
+	return r == '。' || r == '！' || r == '？'
+}
+
+// cutWord cuts a word into pieces of at most limit weighted characters.
+func cutWord(word string, limit int) []string {
+	var out []string
+	start, w := 0, 0
+	for i, r := range word {
+		rw := runeWeight(r)
+		if w+rw > limit {
+			out = append(out, word[start:i])
+			start, w = i, 0
+		}
+		w += rw
+	}
+	if start < len(word) || len(out) == 0 {
+		out = append(out, word[start:])
+	}
+	return out
+}
@@ -0,0 +1,184 @@
+package twitter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWeightedLength(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"café", 4},
+		{"a—b", 3}, // general punctuation is light
+		{"日本語", 6},
+		{"😀", 2},
+		{"https://example.com/a/very/long/path/that/is/longer/than/23", urlWeight},
+		{"see https://x.co now", 4 + urlWeight + 4},
+		{"日本 https://example.com", 4 + 1 + urlWeight},
+	}
+	for _, tt := range tests {
+		if got := weightedLength(tt.text); got != tt.want {
+			t.Errorf("weightedLength(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+// checkThread verifies that every part fits in a tweet and is numbered i/n, and returns the
+// parts without their numbering.
+func checkThread(t *testing.T, parts []string) []string {
+	t.Helper()
+	bodies := make([]string, len(parts))
+	for i, p := range parts {
+		if w := weightedLength(p); w > maxTweetWeight {
+			t.Errorf("part %d weighs %d: %q", i+1, w, p)
+		}
+		suffix := fmt.Sprintf(" %d/%d", i+1, len(parts))
+		if !strings.HasSuffix(p, suffix) {
+			t.Fatalf("part %d lacks %q: %q", i+1, suffix, p)
+		}
+		bodies[i] = strings.TrimSuffix(p, suffix)
+	}
+	return bodies
+}
+
+func words(n int, word string) string {
+	return strings.TrimSpace(strings.Repeat(word+" ", n))
+}
+
+func TestSplitThreadFits(t *testing.T) {
+	tests := []string{
+		strings.Repeat("a", maxTweetWeight),
+		words(maxTweetWeight/2, "a"), // "a a a …" is 279 long
+		strings.Repeat("日", maxTweetWeight/2),
+		strings.Repeat("b", maxTweetWeight-urlWeight-1) + " https://example.com/" + strings.Repeat("p", 100),
+		"  padded  ",
+	}
+	for _, text := range tests {
+		parts := splitThread(text)
+		if len(parts) != 1 || parts[0] != strings.TrimSpace(text) {
+			t.Errorf("splitThread(%d runes) = %d parts, want the text unchanged", len([]rune(text)), len(parts))
+		}
+	}
+}
+
+func TestSplitThreadOneOver(t *testing.T) {
+	text := words(maxTweetWeight/2, "a") + " bc" // 282
+	parts := splitThread(text)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts: %q", len(parts), parts)
+	}
+	if got := strings.Join(checkThread(t, parts), " "); got != text {
+		t.Errorf("rejoined text differs:\n got %q\nwant %q", got, text)
+	}
+}
+
+func TestSplitThreadPrefersSentences(t *testing.T) {
+	first := "First sentence " + words(50, "xx") + "."
+	second := "Second sentence " + words(50, "yy") + "!"
+	third := "Third one " + words(30, "zz") + "?"
+	parts := checkThread(t, splitThread(first+" "+second+" "+third))
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts: %q", len(parts), parts)
+	}
+	if parts[0] != first {
+		t.Errorf("first part = %q", parts[0])
+	}
+	if parts[1] != second+" "+third {
+		t.Errorf("second part = %q", parts[1])
+	}
+}
+
+func TestSplitThreadURLAtBoundary(t *testing.T) {
+	url := "https://example.com/" + strings.Repeat("p", 60)
+	// The URL starts right before the limit: it must move to the next part whole
+	text := words(125, "ab") + " " + url + " tail"
+	parts := splitThread(text)
+	bodies := checkThread(t, parts)
+	found := false
+	for _, b := range bodies {
+		if strings.Contains(b, url) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("URL was cut: %q", parts)
+	}
+	if got := strings.Join(bodies, " "); got != text {
+		t.Errorf("rejoined text differs:\n got %q\nwant %q", got, text)
+	}
+}
+
+func TestSplitThreadCJK(t *testing.T) {
+	sentence := strings.Repeat("漢", 30) + "。"
+	text := strings.Repeat(sentence, 10) // 620 weighted, no spaces
+	bodies := checkThread(t, splitThread(text))
+	if len(bodies) != 3 {
+		t.Errorf("got %d parts, want 3", len(bodies))
+	}
+	for i, b := range bodies {
+		if !strings.HasSuffix(b, "。") {
+			t.Errorf("part %d not broken at a full stop: %q", i+1, b)
+		}
+	}
+	if got := strings.Join(bodies, ""); got != text {
+		t.Error("rejoined CJK text differs")
+	}
+
+	// Without full stops the text is one overlong word and is cut by weight
+	text = strings.Repeat("漢", 300)
+	bodies = checkThread(t, splitThread(text))
+	if got := strings.Join(bodies, ""); got != text {
+		t.Error("rejoined cut CJK text differs")
+	}
+}
+
+func TestSplitThreadOverlongWord(t *testing.T) {
+	word := strings.Repeat("x", 700)
+	bodies := checkThread(t, splitThread("start "+word+" end"))
+	joined := strings.Join(bodies, "")
+	if !strings.Contains(strings.ReplaceAll(joined, " ", ""), word) {
+		t.Errorf("word not preserved across parts: %q", bodies)
+	}
+	for i, b := range bodies {
+		if b == "" {
+			t.Errorf("part %d is empty", i+1)
+		}
+	}
+}
+
+func TestSplitThreadManyParts(t *testing.T) {
+	// More than 9 parts: the suffix grows to " nn/nn" and must still fit
+	text := words(2000, "word")
+	parts := splitThread(text)
+	if len(parts) < 10 {
+		t.Fatalf("got %d parts, want at least 10", len(parts))
+	}
+	if got := strings.Join(checkThread(t, parts), " "); got != text {
+		t.Error("rejoined text differs")
+	}
+}
+
+func TestCutWord(t *testing.T) {
+	tests := []struct {
+		word  string
+		limit int
+		want  []string
+	}{
+		{"abcdef", 2, []string{"ab", "cd", "ef"}},
+		{"abcde", 2, []string{"ab", "cd", "e"}},
+		{"日本語", 4, []string{"日本", "語"}},
+		{"a日", 2, []string{"a", "日"}},
+		{"", 5, []string{""}},
+	}
+	for _, tt := range tests {
+		got := cutWord(tt.word, tt.limit)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("cutWord(%q, %d) = %q, want %q", tt.word, tt.limit, got, tt.want)
+		}
+	}
+}
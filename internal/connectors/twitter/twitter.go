@@ -1,30 +1,38 @@
 package twitter
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dghubble/oauth1"
 	tw "github.com/g8rswimmer/go-twitter/v2"
+
+	"trinity_bot/internal/connectors"
 )
 
 type Client struct {
-	api *tw.Client
+	api       *tw.Client
+	uploadURL string
 }
 
+// Credentials are either OAuth 1.0a keys or, for accounts connected through OAuth 2.0, a user access token.
 type Credentials struct {
 	ConsumerKey    string
 	ConsumerSecret string
 	AccessToken    string
 	AccessSecret   string
+	OAuth2Token    string // OAuth 2.0 user token; the OAuth 1.0a fields are ignored when set
 }
 
 // noopAuthorizer lets the underlying oauth1 http.Client sign requests.
@@ -32,9 +40,24 @@ type noopAuthorizer struct{}
 
 func (n noopAuthorizer) Add(req *http.Request) {}
 
+// bearerAuthorizer authorizes requests with an OAuth 2.0 user token.
+type bearerAuthorizer struct{ token string }
+
+func (a bearerAuthorizer) Add(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+}
+
 func New(creds Credentials) (*Client, error) {
+	if creds.OAuth2Token != "" {
+		api := &tw.Client{
+			Authorizer: bearerAuthorizer{token: creds.OAuth2Token},
+			Client:     &http.Client{Timeout: 20 * time.Second},
+			Host:       "https://api.twitter.com",
+		}
+		return &Client{api: api, uploadURL: uploadURLv2}, nil
+	}
 	if creds.ConsumerKey == "" || creds.ConsumerSecret == "" || creds.AccessToken == "" || creds.AccessSecret == "" {
-		return nil, errors.New("twitter credentials incomplete")
+		return nil, connectors.AuthError("twitter", "twitter credentials incomplete")
 	}
 	conf := oauth1.NewConfig(creds.ConsumerKey, creds.ConsumerSecret)
 	token := oauth1.NewToken(creds.AccessToken, creds.AccessSecret)
@@ -46,45 +69,70 @@ func New(creds Credentials) (*Client, error) {
 		Client:     httpClient,
 		Host:       "https://api.twitter.com",
 	}
-	return &Client{api: api}, nil
+	return &Client{api: api, uploadURL: uploadURL}, nil
 }
 
-// Publish posts a text tweet using Twitter API v2.
-func (c *Client) Publish(ctx context.Context, text string, mediaContents [][]byte, mediaTypes []string) (string, error) {
+const uploadURL = "https://upload.twitter.com/1.1/media/upload.json"
+
+// uploadURLv2 takes the same commands as uploadURL and, unlike it, accepts OAuth 2.0 user tokens.
+const uploadURLv2 = "https://api.twitter.com/2/media/upload"
+
+// chunkSize is the APPEND segment size; the API accepts segments up to 5 MB.
+const chunkSize = 4 << 20
+
+// Tweet posts a tweet with optional media using Twitter API v2. A tweet carries up to 4
+// images or a single video/GIF; the caller picks a valid combination.
+func (c *Client) Tweet(ctx context.Context, text string, mediaContents [][]byte, mediaTypes []string) (string, error) {
+	ids, err := c.Thread(ctx, []string{text}, mediaContents, mediaTypes)
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// Thread posts the parts as a thread: the media goes on the first tweet and every further
+// tweet replies to the previous one. Returns the tweet IDs in order.
+//
+// If the thread breaks off after the first tweet, the error reports the tweets already posted
+// and is not retryable, since a retry would post them again.
+func (c *Client) Thread(ctx context.Context, parts []string, mediaContents [][]byte, mediaTypes []string) ([]string, error) {
 	if c == nil || c.api == nil {
-		return "", errors.New("twitter client nil")
+		return nil, errors.New("twitter client nil")
 	}
-	// Upload up to 4 images via v1.1 media/upload and tweet with media_ids
-	var mediaIDs []string
-	if len(mediaContents) > 0 {
-		if len(mediaContents) != len(mediaTypes) {
-			return "", fmt.Errorf("len(mediaContents) != len(mediaTypes)")
+	if len(parts) == 0 {
+		return nil, errors.New("twitter: nothing to post")
+	}
+	mediaIDs, err := c.uploadMedia(ctx, mediaContents, mediaTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(parts))
+	for i, text := range parts {
+		req := tw.CreateTweetRequest{Text: text}
+		if i == 0 && len(mediaIDs) > 0 {
+			req.Media = &tw.CreateTweetMedia{IDs: mediaIDs}
 		}
-		count := len(mediaContents)
-		if count > 4 {
-			count = 4
+		if i > 0 {
+			req.Reply = &tw.CreateTweetReply{InReplyToTweetID: ids[i-1]}
 		}
-		for i := 0; i < count; i++ {
-			ctype := strings.ToLower(mediaTypes[i])
-			if !strings.HasPrefix(ctype, "image/") {
-				// skip non-image for now
-				continue
+		id, err := c.createTweet(ctx, req)
+		if err != nil {
+			if i == 0 {
+				return nil, err
 			}
-			id, err := c.uploadSimpleMedia(ctx, mediaContents[i])
-			if err != nil {
-				return "", fmt.Errorf("media upload failed: %w", err)
-			}
-			mediaIDs = append(mediaIDs, id)
+			return ids, &connectors.Error{Platform: "twitter", Kind: connectors.KindUnknown, Err: fmt.Errorf(
+				"thread interrupted after %d of %d tweets (posted: %s): %w", i, len(parts), strings.Join(ids, ","), err)}
 		}
+		ids = append(ids, id)
 	}
+	return ids, nil
+}
 
-	req := tw.CreateTweetRequest{Text: text}
-	if len(mediaIDs) > 0 {
-		req.Media = &tw.CreateTweetMedia{IDs: mediaIDs}
-	}
+func (c *Client) createTweet(ctx context.Context, req tw.CreateTweetRequest) (string, error) {
 	resp, err := c.api.CreateTweet(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("create tweet: %w", err)
+		return "", tweetError(fmt.Errorf("create tweet: %w", err))
 	}
 	if resp == nil || resp.Tweet == nil {
 		return "", errors.New("empty response from twitter")
@@ -92,6 +140,37 @@ func (c *Client) Publish(ctx context.Context, text string, mediaContents [][]byt
 	return resp.Tweet.ID, nil
 }
 
+// uploadMedia uploads up to 4 items via v1.1 media/upload and returns their media IDs.
+// Types Twitter does not accept are skipped.
+func (c *Client) uploadMedia(ctx context.Context, mediaContents [][]byte, mediaTypes []string) ([]string, error) {
+	if len(mediaContents) != len(mediaTypes) {
+		return nil, fmt.Errorf("len(mediaContents) != len(mediaTypes)")
+	}
+	var mediaIDs []string
+	count := len(mediaContents)
+	if count > 4 {
+		count = 4
+	}
+	for i := 0; i < count; i++ {
+		ctype := strings.ToLower(mediaTypes[i])
+		var id string
+		var err error
+		switch category := mediaCategory(ctype); category {
+		case "tweet_image":
+			id, err = c.uploadSimpleMedia(ctx, mediaContents[i])
+		case "":
+			continue // not supported by Twitter
+		default:
+			id, err = c.uploadChunkedMedia(ctx, mediaContents[i], ctype, category)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("media upload failed: %w", err)
+		}
+		mediaIDs = append(mediaIDs, id)
+	}
+	return mediaIDs, nil
+}
+
 // uploadSimpleMedia uploads an image using v1.1 simple upload and returns media_id_string.
 func (c *Client) uploadSimpleMedia(ctx context.Context, b []byte) (string, error) {
 	if c == nil || c.api == nil || c.api.Client == nil {
@@ -102,35 +181,240 @@ func (c *Client) uploadSimpleMedia(ctx context.Context, b []byte) (string, error
 	form := url.Values{}
 	form.Set("media", enc)
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://upload.twitter.com/1.1/media/upload.json", strings.NewReader(form.Encode()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uploadURL, strings.NewReader(form.Encode()))
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := c.api.Client.Do(req)
+	var out uploadResponse
+	if err := c.doUpload(req, &out); err != nil {
+		return "", err
+	}
+	return out.id()
+}
+
+// mediaCategory maps a MIME type to the media_category of the upload API, or "" if
+// Twitter does not accept the type.
+func mediaCategory(ctype string) string {
+	switch {
+	case ctype == "image/gif":
+		return "tweet_gif"
+	case strings.HasPrefix(ctype, "image/"):
+		return "tweet_image"
+	case strings.HasPrefix(ctype, "video/"):
+		return "tweet_video"
+	default:
+		return ""
+	}
+}
+
+// uploadResponse is the response of the media/upload commands.
+// The v2 endpoint wraps it in "data" and names the ID "id".
+type uploadResponse struct {
+	Data           *uploadResponse `json:"data"`
+	ID             string          `json:"id"`
+	MediaIDString  string          `json:"media_id_string"`
+	MediaID        int64           `json:"media_id"`
+	ProcessingInfo *struct {
+		State          string `json:"state"` // pending | in_progress | succeeded | failed
+		CheckAfterSecs int    `json:"check_after_secs"`
+		Error          *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"processing_info"`
+}
+
+func (r *uploadResponse) id() (string, error) {
+	if r.MediaIDString != "" {
+		return r.MediaIDString, nil
+	}
+	if r.ID != "" {
+		return r.ID, nil
+	}
+	if r.MediaID != 0 {
+		return fmt.Sprintf("%d", r.MediaID), nil
+	}
+	return "", errors.New("missing media id in response")
+}
+
+// uploadChunkedMedia uploads a video or GIF with the INIT/APPEND/FINALIZE commands and, if
+// Twitter processes the file asynchronously, polls STATUS until it is ready.
+func (c *Client) uploadChunkedMedia(ctx context.Context, b []byte, ctype, category string) (string, error) {
+	if c == nil || c.api == nil || c.api.Client == nil {
+		return "", errors.New("nil twitter http client")
+	}
+	// INIT
+	form := url.Values{}
+	form.Set("command", "INIT")
+	form.Set("total_bytes", strconv.Itoa(len(b)))
+	form.Set("media_type", ctype)
+	form.Set("media_category", category)
+	var initResp uploadResponse
+	if err := c.postUploadForm(ctx, form, &initResp); err != nil {
+		return "", fmt.Errorf("upload INIT: %w", err)
+	}
+	mediaID, err := initResp.id()
 	if err != nil {
 		return "", err
 	}
+
+	// APPEND
+	for seg, off := 0, 0; off < len(b); seg, off = seg+1, off+chunkSize {
+		end := min(off+chunkSize, len(b))
+		if err := c.appendSegment(ctx, mediaID, seg, b[off:end]); err != nil {
+			return "", fmt.Errorf("upload APPEND segment %d: %w", seg, err)
+		}
+	}
+
+	// FINALIZE
+	form = url.Values{}
+	form.Set("command", "FINALIZE")
+	form.Set("media_id", mediaID)
+	var fin uploadResponse
+	if err := c.postUploadForm(ctx, form, &fin); err != nil {
+		return "", fmt.Errorf("upload FINALIZE: %w", err)
+	}
+
+	// STATUS, at the interval FINALIZE suggests; the first check uses FINALIZE's own state
+	info := fin.ProcessingInfo
+	if info == nil {
+		return mediaID, nil
+	}
+	interval := time.Duration(max(info.CheckAfterSecs, 1)) * time.Second
+	first := true
+	err = connectors.PollUntilReady(ctx, "twitter", interval, 0, func(ctx context.Context) (bool, error) {
+		if !first {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.uploadURL+"?command=STATUS&media_id="+url.QueryEscape(mediaID), nil)
+			if err != nil {
+				return false, err
+			}
+			var st uploadResponse
+			if err := c.doUpload(req, &st); err != nil {
+				return false, fmt.Errorf("upload STATUS: %w", err)
+			}
+			info = st.ProcessingInfo
+		}
+		first = false
+		if info == nil {
+			return true, nil
+		}
+		switch info.State {
+		case "succeeded":
+			return true, nil
+		case "failed":
+			msg := "unknown error"
+			if info.Error != nil {
+				msg = info.Error.Message
+			}
+			return false, connectors.ValidationError("twitter", fmt.Sprintf("media processing failed: %s", msg))
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return mediaID, nil
+}
+
+// appendSegment sends one APPEND segment as multipart form data.
+func (c *Client) appendSegment(ctx context.Context, mediaID string, index int, data []byte) error {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	_ = w.WriteField("command", "APPEND")
+	_ = w.WriteField("media_id", mediaID)
+	_ = w.WriteField("segment_index", strconv.Itoa(index))
+	fw, err := w.CreateFormFile("media", "blob")
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uploadURL, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return c.doUpload(req, nil)
+}
+
+func (c *Client) postUploadForm(ctx context.Context, form url.Values, out *uploadResponse) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.uploadURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.doUpload(req, out)
+}
+
+// doUpload sends a signed request to the upload API and decodes the JSON response into out,
+// if given (APPEND answers 204 No Content).
+func (c *Client) doUpload(req *http.Request, out *uploadResponse) error {
+	c.api.Authorizer.Add(req)
+	resp, err := c.api.Client.Do(req)
+	if err != nil {
+		return connectors.NetworkError("twitter", err)
+	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("upload status %d: %s", resp.StatusCode, string(body))
+		e := connectors.StatusError("twitter", resp, fmt.Errorf("upload status %d: %s", resp.StatusCode, string(body)))
+		if e.Kind == connectors.KindRateLimited && e.RetryAfter == 0 {
+			e.RetryAfter = resetAfter(resp.Header.Get("x-rate-limit-reset"))
+		}
+		return e
 	}
-	// Parse JSON response
-	var out struct {
-		MediaIDString string `json:"media_id_string"`
-		MediaID       int64  `json:"media_id"`
+	if out == nil {
+		return nil
 	}
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&out); err != nil {
-		return "", err
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return err
 	}
-	if out.MediaIDString != "" {
-		return out.MediaIDString, nil
+	if out.Data != nil {
+		*out = *out.Data
 	}
-	if out.MediaID != 0 {
-		return fmt.Sprintf("%d", out.MediaID), nil
+	return nil
+}
+
+// tweetError classifies an error returned by the go-twitter client, using the rate limit
+// reset time as the retry delay when the request was throttled.
+func tweetError(err error) error {
+	var status int
+	var rl *tw.RateLimit
+	var er *tw.ErrorResponse
+	var he *tw.HTTPError
+	var ue *url.Error
+	switch {
+	case errors.As(err, &er):
+		status, rl = er.StatusCode, er.RateLimit
+	case errors.As(err, &he):
+		status, rl = he.StatusCode, he.RateLimit
+	case errors.As(err, &ue):
+		return connectors.NetworkError("twitter", err)
+	default:
+		return err
 	}
-	return "", errors.New("missing media id in response")
+	e := &connectors.Error{Platform: "twitter", Kind: connectors.KindForStatus(status), StatusCode: status, Err: err}
+	if e.Kind == connectors.KindRateLimited && rl != nil {
+		if d := time.Until(rl.Reset.Time()); d > 0 {
+			e.RetryAfter = d
+		}
+	}
+	return e
+}
+
+// resetAfter converts an x-rate-limit-reset header (unix seconds) into a delay from now.
+func resetAfter(v string) time.Duration {
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0
+	}
+	if d := time.Until(time.Unix(secs, 0)); d > 0 {
+		return d
+	}
+	return 0
 }
@@ -0,0 +1,6 @@
+-- 0003_publish_queue.sql: durable publish queue drained by the background worker
+
+-- Set when a worker claims a target; stale claims are picked up again after a restart
+ALTER TABLE post_targets ADD COLUMN IF NOT EXISTS locked_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_post_targets_status ON post_targets(status);
@@ -0,0 +1,5 @@
+-- 0004_scheduled_posts.sql: publish posts at a planned time
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_posts_scheduled ON posts(scheduled_at) WHERE status = 'scheduled';
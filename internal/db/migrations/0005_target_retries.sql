@@ -0,0 +1,4 @@
+-- 0005_target_retries.sql: automatic retries of transient publish failures
+
+ALTER TABLE post_targets ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE post_targets ADD COLUMN IF NOT EXISTS next_retry_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+-- 0006_media_descriptions.sql: alt text per attachment, used by platforms that support it
+
+ALTER TABLE post_media ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
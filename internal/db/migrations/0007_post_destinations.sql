@@ -0,0 +1,5 @@
+-- 0007_post_destinations.sql: destination link and Pinterest board/section chosen per post
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS link TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS pinterest_board_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS pinterest_section_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,23 @@
+-- 0008_platform_accounts.sql: several accounts per platform; targets reference the account they publish to
+
+CREATE TABLE IF NOT EXISTS platform_accounts (
+    id                BIGSERIAL PRIMARY KEY,
+    platform          TEXT NOT NULL,
+    name              TEXT NOT NULL,                -- label shown on the target keyboard, e.g. the brand
+    external_id       TEXT NOT NULL DEFAULT '',     -- page/user/organization/channel ID or handle on the platform
+    access_token      TEXT NOT NULL DEFAULT '',
+    access_secret     TEXT NOT NULL DEFAULT '',     -- OAuth 1.0a token secret (Twitter) or app password (Bluesky)
+    refresh_token     TEXT NOT NULL DEFAULT '',
+    token_expires_at  TIMESTAMPTZ,
+    settings          JSONB NOT NULL DEFAULT '{}',  -- non-secret options, e.g. instance_url, board_id
+    owner_user_id     BIGINT NOT NULL DEFAULT 0,    -- Telegram user who added the account
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(platform, external_id)
+);
+
+-- NULL account_id means the platform's credentials from the environment
+ALTER TABLE post_targets ADD COLUMN IF NOT EXISTS account_id BIGINT REFERENCES platform_accounts(id);
+
+ALTER TABLE post_targets DROP CONSTRAINT IF EXISTS post_targets_post_id_platform_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_targets_account ON post_targets(post_id, platform, COALESCE(account_id, 0));
@@ -0,0 +1,5 @@
+-- 0009_token_expiry_warnings.sql: remember when the owner was warned about an expiring token
+
+ALTER TABLE platform_accounts ADD COLUMN IF NOT EXISTS expiry_warned_at TIMESTAMPTZ; -- cleared when the tokens change
+
+CREATE INDEX IF NOT EXISTS idx_platform_accounts_token_expires_at ON platform_accounts(token_expires_at) WHERE token_expires_at IS NOT NULL;
@@ -0,0 +1,204 @@
+// Package media serves copies of Telegram files to platforms that download media from a URL
+// (Instagram, Threads, TikTok pull mode). Files are cached on disk and exposed under short-lived
+// HMAC-signed URLs, so the bot token never leaves the bot.
+package media
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Path is the URL path prefix media is served under.
+const Path = "/media/"
+
+// typeSuffix names the file next to a cached file that holds its content type.
+const typeSuffix = ".type"
+
+// FetchFunc downloads a file and returns its bytes and content type.
+type FetchFunc func(ctx context.Context, fileID string) ([]byte, string, error)
+
+// Server caches files and serves them under signed URLs. It is an http.Handler serving Path.
+type Server struct {
+	dir     string
+	baseURL string
+	key     []byte
+	ttl     time.Duration
+
+	mu     sync.Mutex // serializes writes to the cache directory
+	pruned time.Time
+}
+
+// New returns a server caching files in dir and handing out URLs on baseURL that stay valid
+// for ttl. An empty signingKey uses a random key, which invalidates URLs on restart.
+func New(dir, baseURL, signingKey string, ttl time.Duration) (*Server, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create media cache dir: %w", err)
+	}
+	key := []byte(signingKey)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Server{dir: dir, baseURL: strings.TrimRight(baseURL, "/"), key: key, ttl: ttl}, nil
+}
+
+// URL caches a file, fetching it unless it is cached already, and returns a signed URL for it.
+func (s *Server) URL(ctx context.Context, fileID string, fetch FetchFunc) (string, error) {
+	sum := sha256.Sum256([]byte(fileID))
+	name := hex.EncodeToString(sum[:16])
+	if err := s.store(ctx, name, fileID, fetch); err != nil {
+		return "", err
+	}
+	s.prune()
+
+	exp := strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)
+	q := url.Values{}
+	q.Set("exp", exp)
+	q.Set("sig", s.sign(name, exp))
+	return s.baseURL + Path + name + "?" + q.Encode(), nil
+}
+
+// store writes a file to the cache. A cached file is touched instead, so pruning keeps it as
+// long as a URL handed out for it is valid.
+func (s *Server) store(ctx context.Context, name, fileID string, fetch FetchFunc) error {
+	path := filepath.Join(s.dir, name)
+	now := time.Now()
+	s.mu.Lock()
+	err := os.Chtimes(path, now, now)
+	s.mu.Unlock()
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("touch cached media: %w", err)
+	}
+
+	data, ctype, err := fetch(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := writeFile(path+typeSuffix, []byte(ctype)); err != nil {
+		return fmt.Errorf("cache media: %w", err)
+	}
+	if err := writeFile(path, data); err != nil {
+		return fmt.Errorf("cache media: %w", err)
+	}
+	return nil
+}
+
+// writeFile writes through a temporary file so readers never see partial content.
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// prune removes files not touched within the TTL; no valid URL points to them. It runs at most
+// once per minute.
+func (s *Server) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.pruned) < time.Minute {
+		return
+	}
+	s.pruned = now
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		slog.Warn("List media cache error", "err", err)
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), typeSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) <= s.ttl {
+			continue
+		}
+		path := filepath.Join(s.dir, e.Name())
+		_ = os.Remove(path)
+		_ = os.Remove(path + typeSuffix)
+	}
+}
+
+// sign returns the signature of a file name and expiry.
+func (s *Server) sign(name, exp string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(name + "|" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves a cached file if the URL's signature is valid and it has not expired.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, Path)
+	if _, err := hex.DecodeString(name); err != nil || name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	exp := q.Get("exp")
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get("sig"))
+	want, _ := base64.RawURLEncoding.DecodeString(s.sign(name, exp))
+	if err != nil || !hmac.Equal(sig, want) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if ts, err := strconv.ParseInt(exp, 10, 64); err != nil || time.Now().Unix() > ts {
+		http.Error(w, "link expired", http.StatusGone)
+		return
+	}
+
+	path := filepath.Join(s.dir, name)
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if ctype, err := os.ReadFile(path + typeSuffix); err == nil && len(ctype) > 0 {
+		w.Header().Set("Content-Type", string(ctype))
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
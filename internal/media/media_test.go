@@ -0,0 +1,120 @@
+package media
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := New(t.TempDir(), "https://bot.example.com/", "signing-key", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// cachedURL caches a small JPEG-typed file and returns its signed URL.
+func cachedURL(t *testing.T, s *Server, fileID string) *url.URL {
+	t.Helper()
+	raw, err := s.URL(context.Background(), fileID, func(context.Context, string) ([]byte, string, error) {
+		return []byte("image-bytes"), "image/jpeg", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := newTestServer(t)
+	u := cachedURL(t, s, "file-1")
+	if !strings.HasPrefix(u.String(), "https://bot.example.com"+Path) {
+		t.Fatalf("URL = %s", u)
+	}
+	name := strings.TrimPrefix(u.Path, Path)
+	exp, sig := u.Query().Get("exp"), u.Query().Get("sig")
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	later := strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10)
+	unknown := strings.Repeat("ab", 16)
+
+	tampered := []byte(sig)
+	tampered[0] ^= 1
+
+	query := func(name, exp, sig string) string {
+		return Path + name + "?" + url.Values{"exp": {exp}, "sig": {sig}}.Encode()
+	}
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		status   int
+		wantBody bool
+	}{
+		{"valid", http.MethodGet, u.RequestURI(), http.StatusOK, true},
+		{"valid head", http.MethodHead, u.RequestURI(), http.StatusOK, false},
+		{"tampered signature", http.MethodGet, query(name, exp, string(tampered)), http.StatusForbidden, false},
+		{"missing signature", http.MethodGet, query(name, exp, ""), http.StatusForbidden, false},
+		{"extended expiry", http.MethodGet, query(name, later, sig), http.StatusForbidden, false},
+		{"signature of another file", http.MethodGet, query(unknown, exp, sig), http.StatusForbidden, false},
+		{"expired", http.MethodGet, query(name, past, s.sign(name, past)), http.StatusGone, false},
+		{"unknown file", http.MethodGet, query(unknown, exp, s.sign(unknown, exp)), http.StatusNotFound, false},
+		{"not a cache name", http.MethodGet, query("..%2Fetc", exp, sig), http.StatusNotFound, false},
+		{"post", http.MethodPost, u.RequestURI(), http.StatusMethodNotAllowed, false},
+		{"delete", http.MethodDelete, u.RequestURI(), http.StatusMethodNotAllowed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "image/jpeg" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if got := rec.Body.String(); (got == "image-bytes") != tt.wantBody {
+				t.Errorf("body = %q", got)
+			}
+		})
+	}
+}
+
+func TestURLCachesOnce(t *testing.T) {
+	s := newTestServer(t)
+	fetches := 0
+	fetch := func(context.Context, string) ([]byte, string, error) {
+		fetches++
+		return []byte("video"), "video/mp4", nil
+	}
+	first, err := s.URL(context.Background(), "file-1", fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.URL(context.Background(), "file-1", fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 1 {
+		t.Errorf("fetches = %d, want 1", fetches)
+	}
+	if strings.Split(first, "?")[0] != strings.Split(second, "?")[0] {
+		t.Errorf("same file got different paths: %s, %s", first, second)
+	}
+	if strings.Contains(first, "file-1") {
+		t.Errorf("URL exposes the file ID: %s", first)
+	}
+}
@@ -0,0 +1,379 @@
+// Package oauth links platform accounts through the OAuth 2.0 authorization code flow: /connect
+// hands out an authorization URL, and the callback handler exchanges the code for tokens and
+// stores the accounts the tokens give access to.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"trinity_bot/internal/connectors"
+	"trinity_bot/internal/storage"
+)
+
+// CallbackPath is the path of the redirect URI registered with every platform.
+const CallbackPath = "/oauth/callback"
+
+// stateTTL is how long an authorization link stays valid.
+const stateTTL = 15 * time.Minute
+
+// Provider describes a platform's authorization code flow.
+type Provider struct {
+	Name         string // key used by /connect, e.g. "twitter" or "meta"
+	AuthURL      string
+	TokenURL     string
+	APIBaseURL   string // API used to look up the connected accounts
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	ScopeSep     string // separator of scopes in the authorization URL (default " ")
+	PKCE         bool   // send an S256 code challenge
+	BasicAuth    bool   // send the client credentials in the Authorization header of token requests
+
+	// accounts looks up the accounts tok gives access to; their tokens are filled in by the lookup.
+	accounts func(ctx context.Context, c *http.Client, p *Provider, tok *Token) ([]storage.Account, error)
+	// refresh renews an account's token; nil uses the refresh_token grant.
+	refresh func(ctx context.Context, c *http.Client, p *Provider, a *storage.Account) (*Token, error)
+}
+
+// Token is the result of a token request.
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    *time.Time // nil if the token does not expire
+}
+
+// Result is the outcome of one authorization, reported to the chat that started it.
+type Result struct {
+	Provider string
+	UserID   int64
+	ChatID   int64
+	Accounts []storage.Account // stored accounts, with their IDs
+	Owned    []storage.Account // accounts found but connected by another user; left unchanged
+	Err      error
+}
+
+// pending is an authorization link handed out and not used yet.
+type pending struct {
+	provider string
+	userID   int64
+	chatID   int64
+	verifier string
+	expires  time.Time
+}
+
+// Flow runs authorizations for a set of providers and refreshes the tokens they issued. It is an
+// http.Handler serving CallbackPath.
+type Flow struct {
+	providers   map[string]*Provider
+	aliases     map[string]string
+	redirectURL string
+	accounts    storage.AccountRepository
+	httpClient  *http.Client
+	onDone      func(Result)
+
+	mu      sync.Mutex
+	pending map[string]pending // key: state
+}
+
+// New returns a flow for the given providers. redirectURL is the public URL of CallbackPath;
+// onDone is called after every callback.
+func New(providers []*Provider, redirectURL string, accounts storage.AccountRepository, onDone func(Result)) *Flow {
+	f := &Flow{
+		providers:   make(map[string]*Provider, len(providers)),
+		aliases:     map[string]string{"x": "twitter", "facebook": "meta", "instagram": "meta"},
+		redirectURL: redirectURL,
+		accounts:    accounts,
+		httpClient:  &http.Client{Timeout: 20 * time.Second},
+		onDone:      onDone,
+		pending:     make(map[string]pending),
+	}
+	for _, p := range providers {
+		f.providers[p.Name] = p
+	}
+	return f
+}
+
+// Names returns the configured providers, sorted.
+func (f *Flow) Names() []string {
+	names := make([]string, 0, len(f.providers))
+	for name := range f.providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Has reports whether a provider name or platform alias is configured.
+func (f *Flow) Has(name string) bool {
+	_, ok := f.provider(name)
+	return ok
+}
+
+// provider resolves a provider name or platform alias ("facebook" → "meta").
+func (f *Flow) provider(name string) (*Provider, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := f.aliases[name]; ok {
+		name = alias
+	}
+	p, ok := f.providers[name]
+	return p, ok
+}
+
+// AuthURL starts an authorization for a Telegram user and returns the URL to open. The link can
+// be used once, within stateTTL.
+func (f *Flow) AuthURL(provider string, userID, chatID int64) (string, error) {
+	p, ok := f.provider(provider)
+	if !ok {
+		return "", fmt.Errorf("unknown provider %q", provider)
+	}
+	state, err := randomString(24)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", f.redirectURL)
+	q.Set("state", state)
+	sep := p.ScopeSep
+	if sep == "" {
+		sep = " "
+	}
+	q.Set("scope", strings.Join(p.Scopes, sep))
+	pd := pending{provider: p.Name, userID: userID, chatID: chatID, expires: time.Now().Add(stateTTL)}
+	if p.PKCE {
+		if pd.verifier, err = randomString(48); err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256([]byte(pd.verifier))
+		q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:]))
+		q.Set("code_challenge_method", "S256")
+	}
+
+	f.mu.Lock()
+	now := time.Now()
+	for s, other := range f.pending {
+		if now.After(other.expires) {
+			delete(f.pending, s)
+		}
+	}
+	f.pending[state] = pd
+	f.mu.Unlock()
+
+	sepQ := "?"
+	if strings.Contains(p.AuthURL, "?") {
+		sepQ = "&"
+	}
+	return p.AuthURL + sepQ + q.Encode(), nil
+}
+
+// CanRefresh reports whether an account's token can be renewed without the user: its platform's
+// provider is configured and it has a refresh token (or, for Meta, a long-lived token to exchange).
+func (f *Flow) CanRefresh(a *storage.Account) bool {
+	p, ok := f.provider(a.Platform)
+	if !ok || a.AccessToken == "" {
+		return false
+	}
+	return p.refresh != nil || a.RefreshToken != ""
+}
+
+// Refresh renews an account's token. Refresh tokens the platform does not rotate are kept.
+func (f *Flow) Refresh(ctx context.Context, a *storage.Account) (*Token, error) {
+	if !f.CanRefresh(a) {
+		return nil, fmt.Errorf("%s account %d cannot be refreshed", a.Platform, a.ID)
+	}
+	p, _ := f.provider(a.Platform)
+	var tok *Token
+	var err error
+	if p.refresh != nil {
+		tok, err = p.refresh(ctx, f.httpClient, p, a)
+	} else {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", a.RefreshToken)
+		tok, err = f.token(ctx, p, form)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = a.RefreshToken
+	}
+	return tok, nil
+}
+
+// take removes and returns the pending authorization of a state.
+func (f *Flow) take(state string) (pending, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pd, ok := f.pending[state]
+	delete(f.pending, state)
+	if !ok || time.Now().After(pd.expires) {
+		return pending{}, false
+	}
+	return pd, true
+}
+
+// ServeHTTP handles the platform's redirect back to CallbackPath.
+func (f *Flow) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pd, ok := f.take(q.Get("state"))
+	if !ok {
+		http.Error(w, "This authorization link is invalid or has expired. Run /connect again.", http.StatusBadRequest)
+		return
+	}
+	res := Result{Provider: pd.provider, UserID: pd.userID, ChatID: pd.chatID}
+	if e := q.Get("error"); e != "" {
+		res.Err = fmt.Errorf("authorization denied: %s", strings.TrimSpace(e+" "+q.Get("error_description")))
+	} else if code := q.Get("code"); code == "" {
+		res.Err = errors.New("authorization code missing")
+	} else {
+		res.Accounts, res.Owned, res.Err = f.connect(r.Context(), f.providers[pd.provider], code, pd)
+	}
+	if f.onDone != nil {
+		f.onDone(res)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if res.Err != nil {
+		slog.Error("OAuth callback error", "err", res.Err, "provider", pd.provider, "user_id", pd.userID)
+		// The error may quote the platform's response; it is reported to the chat, not the browser
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintln(w, "Connecting failed. The details were sent to your Telegram chat.")
+		return
+	}
+	if len(res.Owned) > 0 {
+		fmt.Fprintf(w, "Connected %d account(s); %d belong to another user. The details were sent to your Telegram chat.\n", len(res.Accounts), len(res.Owned))
+		return
+	}
+	fmt.Fprintf(w, "Connected %d account(s). You can close this window and return to Telegram.\n", len(res.Accounts))
+}
+
+// connect exchanges the code, looks up the accounts and stores them. Accounts already connected
+// by another user are not taken over; they are returned separately.
+func (f *Flow) connect(ctx context.Context, p *Provider, code string, pd pending) (saved, owned []storage.Account, err error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", f.redirectURL)
+	if pd.verifier != "" {
+		form.Set("code_verifier", pd.verifier)
+	}
+	tok, err := f.token(ctx, p, form)
+	if err != nil {
+		return nil, nil, err
+	}
+	accounts, err := p.accounts(ctx, f.httpClient, p, tok)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, nil, errors.New("no accounts found for this login")
+	}
+	for _, a := range accounts {
+		a.OwnerUserID = pd.userID
+		id, err := f.accounts.SaveAccount(ctx, &a)
+		if errors.Is(err, storage.ErrAccountOwned) {
+			slog.Warn("OAuth account owned by another user", "provider", p.Name, "platform", a.Platform, "external_id", a.ExternalID, "user_id", pd.userID)
+			owned = append(owned, a)
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		a.ID = id
+		saved = append(saved, a)
+	}
+	return saved, owned, nil
+}
+
+// token sends a token request with the provider's client credentials.
+func (f *Flow) token(ctx context.Context, p *Provider, form url.Values) (*Token, error) {
+	form.Set("client_id", p.ClientID)
+	if !p.BasicAuth {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.BasicAuth {
+		req.SetBasicAuth(p.ClientID, p.ClientSecret)
+	}
+	var out tokenResponse
+	if err := doJSON(f.httpClient, req, &out); err != nil {
+		return nil, fmt.Errorf("%s token request: %w", p.Name, err)
+	}
+	if out.AccessToken == "" {
+		return nil, fmt.Errorf("%s token request: no access token in response", p.Name)
+	}
+	return out.token(), nil
+}
+
+// tokenResponse is the standard token endpoint response.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (r tokenResponse) token() *Token {
+	t := &Token{AccessToken: r.AccessToken, RefreshToken: r.RefreshToken}
+	if r.ExpiresIn > 0 {
+		at := time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+		t.ExpiresAt = &at
+	}
+	return t
+}
+
+// getJSON sends an authorized GET request to the provider's API and decodes the response.
+func getJSON(ctx context.Context, c *http.Client, rawURL, accessToken string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return doJSON(c, req, out)
+}
+
+// doJSON sends req and decodes a successful JSON response into out. Transport errors lose the
+// request URL, which may carry tokens; the error is shown in the chat.
+func doJSON(c *http.Client, req *http.Request, out any) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return connectors.StripURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// randomString returns n random bytes, base64url-encoded (usable as state and PKCE verifier).
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
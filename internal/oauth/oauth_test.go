@@ -0,0 +1,376 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"trinity_bot/internal/storage"
+)
+
+// fakeAccounts records saved accounts. Accounts whose external ID is in owned belong to
+// another user.
+type fakeAccounts struct {
+	storage.AccountRepository
+	mu    sync.Mutex
+	saved []storage.Account
+	owned map[string]bool
+}
+
+func (f *fakeAccounts) SaveAccount(_ context.Context, a *storage.Account) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.owned[a.ExternalID] {
+		return 0, storage.ErrAccountOwned
+	}
+	f.saved = append(f.saved, *a)
+	return int64(len(f.saved)), nil
+}
+
+// fakeAuthServer is a local authorization server with a token endpoint and a Twitter-like
+// /users/me API.
+type fakeAuthServer struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	forms    []url.Values
+	basic    []string // client ID sent with HTTP Basic auth, "" if none
+	tokenErr bool
+}
+
+func newFakeAuthServer(t *testing.T) *fakeAuthServer {
+	f := &fakeAuthServer{t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse token form: %v", err)
+		}
+		user, pass, ok := r.BasicAuth()
+		f.mu.Lock()
+		f.forms = append(f.forms, r.PostForm)
+		if ok {
+			f.basic = append(f.basic, user+":"+pass)
+		} else {
+			f.basic = append(f.basic, "")
+		}
+		tokenErr := f.tokenErr
+		f.mu.Unlock()
+		if tokenErr {
+			http.Error(w, `{"error":"invalid_grant","secret_detail":"upstream body"}`, http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access-1", "refresh_token": "refresh-1", "expires_in": 7200,
+		})
+	})
+	mux.HandleFunc("/api/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer access-1" {
+			t.Errorf("lookup Authorization = %q", got)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"id": "42", "username": "brand"}})
+	})
+	f.srv = httptest.NewServer(mux)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// twitter returns the Twitter provider pointed at the fake server.
+func (f *fakeAuthServer) twitter(secret string) *Provider {
+	p := Twitter("client-1", secret)
+	p.AuthURL = f.srv.URL + "/authorize"
+	p.TokenURL = f.srv.URL + "/token"
+	p.APIBaseURL = f.srv.URL + "/api"
+	return p
+}
+
+func newTestFlow(p *Provider) (*Flow, *fakeAccounts, *[]Result) {
+	accounts := &fakeAccounts{}
+	var results []Result
+	f := New([]*Provider{p}, "https://bot.example.com"+CallbackPath, accounts, func(r Result) { results = append(results, r) })
+	return f, accounts, &results
+}
+
+func authQuery(t *testing.T, f *Flow, provider string) url.Values {
+	t.Helper()
+	link, err := f.AuthURL(provider, 7, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query()
+}
+
+func callback(f *Flow, query string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	f.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, CallbackPath+"?"+query, nil))
+	return rec
+}
+
+func TestAuthURL(t *testing.T) {
+	srv := newFakeAuthServer(t)
+	f, _, _ := newTestFlow(srv.twitter(""))
+
+	q := authQuery(t, f, "x") // alias of twitter
+	if q.Get("response_type") != "code" || q.Get("client_id") != "client-1" ||
+		q.Get("redirect_uri") != "https://bot.example.com"+CallbackPath {
+		t.Errorf("query = %v", q)
+	}
+	if !strings.Contains(q.Get("scope"), "tweet.write") || !strings.Contains(q.Get("scope"), " ") {
+		t.Errorf("scope = %q", q.Get("scope"))
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("missing PKCE challenge: %v", q)
+	}
+	state := q.Get("state")
+	if len(state) < 32 {
+		t.Errorf("state %q too short", state)
+	}
+	if other := authQuery(t, f, "twitter").Get("state"); other == state {
+		t.Error("states repeat")
+	}
+
+	// The challenge is the S256 hash of the verifier kept for the state
+	pd := f.pending[state]
+	sum := sha256.Sum256([]byte(pd.verifier))
+	if want := base64.RawURLEncoding.EncodeToString(sum[:]); q.Get("code_challenge") != want {
+		t.Errorf("code_challenge = %q, want %q", q.Get("code_challenge"), want)
+	}
+	if pd.userID != 7 || pd.chatID != 99 || pd.provider != "twitter" {
+		t.Errorf("pending = %+v", pd)
+	}
+
+	if _, err := f.AuthURL("myspace", 7, 99); err == nil {
+		t.Error("unknown provider accepted")
+	}
+}
+
+func TestCallbackExchangesCodeAndSavesAccounts(t *testing.T) {
+	srv := newFakeAuthServer(t)
+	f, accounts, results := newTestFlow(srv.twitter(""))
+	q := authQuery(t, f, "twitter")
+	verifier := f.pending[q.Get("state")].verifier
+
+	rec := callback(f, "state="+q.Get("state")+"&code=code-1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	form := srv.forms[0]
+	if form.Get("grant_type") != "authorization_code" || form.Get("code") != "code-1" ||
+		form.Get("redirect_uri") != "https://bot.example.com"+CallbackPath || form.Get("client_id") != "client-1" {
+		t.Errorf("token form = %v", form)
+	}
+	if verifier == "" || form.Get("code_verifier") != verifier {
+		t.Errorf("code_verifier = %q, want %q", form.Get("code_verifier"), verifier)
+	}
+
+	if len(accounts.saved) != 1 {
+		t.Fatalf("saved %d accounts", len(accounts.saved))
+	}
+	a := accounts.saved[0]
+	if a.Platform != "twitter" || a.Name != "@brand" || a.ExternalID != "42" || a.OwnerUserID != 7 ||
+		a.AccessToken != "access-1" || a.RefreshToken != "refresh-1" || a.TokenExpiresAt == nil {
+		t.Errorf("saved account = %+v", a)
+	}
+	if a.TokenExpiresAt != nil && time.Until(*a.TokenExpiresAt) < time.Hour {
+		t.Errorf("expiry = %v", a.TokenExpiresAt)
+	}
+
+	if len(*results) != 1 || (*results)[0].Err != nil || (*results)[0].ChatID != 99 || (*results)[0].Accounts[0].ID != 1 {
+		t.Errorf("results = %+v", *results)
+	}
+
+	// The state is single-use
+	if rec := callback(f, "state="+q.Get("state")+"&code=code-1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("reused state: status = %d", rec.Code)
+	}
+}
+
+func TestCallbackKeepsAccountsOfOtherUsers(t *testing.T) {
+	srv := newFakeAuthServer(t)
+	f, accounts, results := newTestFlow(srv.twitter(""))
+	accounts.owned = map[string]bool{"42": true}
+	q := authQuery(t, f, "twitter")
+
+	rec := callback(f, "state="+q.Get("state")+"&code=code-1")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "another user") {
+		t.Errorf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if len(accounts.saved) != 0 {
+		t.Errorf("saved %+v", accounts.saved)
+	}
+	res := (*results)[0]
+	if res.Err != nil || len(res.Accounts) != 0 || len(res.Owned) != 1 || res.Owned[0].ExternalID != "42" {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		wantBasic string
+		wantForm  string
+	}{
+		{"public client", "", "", ""},
+		{"confidential client", "s3cret", "client-1:s3cret", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeAuthServer(t)
+			f, _, _ := newTestFlow(srv.twitter(tt.secret))
+			q := authQuery(t, f, "twitter")
+			callback(f, "state="+q.Get("state")+"&code=c")
+			if srv.basic[0] != tt.wantBasic {
+				t.Errorf("basic auth = %q, want %q", srv.basic[0], tt.wantBasic)
+			}
+			if got := srv.forms[0].Get("client_secret"); got != tt.wantForm {
+				t.Errorf("client_secret in form = %q, want %q", got, tt.wantForm)
+			}
+		})
+	}
+
+	// Providers without BasicAuth send the secret in the form
+	srv := newFakeAuthServer(t)
+	p := srv.twitter("s3cret")
+	p.BasicAuth = false
+	f, _, _ := newTestFlow(p)
+	q := authQuery(t, f, "twitter")
+	callback(f, "state="+q.Get("state")+"&code=c")
+	if srv.basic[0] != "" || srv.forms[0].Get("client_secret") != "s3cret" {
+		t.Errorf("form auth: basic = %q, form = %v", srv.basic[0], srv.forms[0])
+	}
+}
+
+func TestCallbackFailures(t *testing.T) {
+	srv := newFakeAuthServer(t)
+	f, accounts, results := newTestFlow(srv.twitter(""))
+
+	if rec := callback(f, "state=unknown&code=c"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown state: status = %d", rec.Code)
+	}
+	if len(*results) != 0 {
+		t.Errorf("unknown state reported: %+v", *results)
+	}
+
+	// Expired state
+	q := authQuery(t, f, "twitter")
+	state := q.Get("state")
+	pd := f.pending[state]
+	pd.expires = time.Now().Add(-time.Second)
+	f.pending[state] = pd
+	if rec := callback(f, "state="+state+"&code=c"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expired state: status = %d", rec.Code)
+	}
+
+	// Denied by the user
+	q = authQuery(t, f, "twitter")
+	rec := callback(f, "state="+q.Get("state")+"&error=access_denied&error_description=nope")
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("denied: status = %d", rec.Code)
+	}
+	if len(*results) != 1 || (*results)[0].Err == nil || !strings.Contains((*results)[0].Err.Error(), "access_denied") {
+		t.Errorf("denied result = %+v", *results)
+	}
+
+	// Failed token request: the chat gets the detail, the browser a generic message
+	srv.tokenErr = true
+	q = authQuery(t, f, "twitter")
+	rec = callback(f, "state="+q.Get("state")+"&code=c")
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("token error: status = %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "upstream body") || strings.Contains(rec.Body.String(), "invalid_grant") {
+		t.Errorf("browser sees upstream error: %s", rec.Body)
+	}
+	last := (*results)[len(*results)-1]
+	if last.Err == nil || !strings.Contains(last.Err.Error(), "invalid_grant") {
+		t.Errorf("token error result = %+v", last)
+	}
+	if len(accounts.saved) != 0 {
+		t.Errorf("accounts saved after failures: %+v", accounts.saved)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	srv := newFakeAuthServer(t)
+	f, _, _ := newTestFlow(srv.twitter(""))
+	a := &storage.Account{ID: 1, Platform: "twitter", AccessToken: "old", RefreshToken: "refresh-0"}
+	if !f.CanRefresh(a) {
+		t.Fatal("CanRefresh = false")
+	}
+	tok, err := f.Refresh(context.Background(), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form := srv.forms[0]; form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "refresh-0" {
+		t.Errorf("refresh form = %v", form)
+	}
+	if tok.AccessToken != "access-1" || tok.RefreshToken != "refresh-1" {
+		t.Errorf("token = %+v", tok)
+	}
+	if f.CanRefresh(&storage.Account{Platform: "twitter", AccessToken: "old"}) {
+		t.Error("CanRefresh without refresh token")
+	}
+}
+
+func TestMetaTokenExchange(t *testing.T) {
+	var form url.Values
+	var rawQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/oauth/access_token" {
+			t.Errorf("request = %s %s", r.Method, r.URL)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse form: %v", err)
+		}
+		form, rawQuery = r.PostForm, r.URL.RawQuery
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "long-1"})
+	}))
+	defer srv.Close()
+
+	p := Meta("app-1", "app-secret")
+	p.APIBaseURL = srv.URL
+	tok, err := exchangeMetaToken(context.Background(), srv.Client(), p, "short-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rawQuery != "" {
+		t.Errorf("query = %q, want credentials in the body only", rawQuery)
+	}
+	if form.Get("grant_type") != "fb_exchange_token" || form.Get("client_secret") != "app-secret" || form.Get("fb_exchange_token") != "short-1" {
+		t.Errorf("form = %v", form)
+	}
+	if tok.AccessToken != "long-1" || tok.ExpiresAt == nil {
+		t.Errorf("token = %+v", tok)
+	}
+}
+
+func TestTransportErrorHidesURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close() // every request fails with connection refused
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/me?access_token=user-secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = doJSON(http.DefaultClient, req, &struct{}{})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), "user-secret") || strings.Contains(err.Error(), srv.URL) {
+		t.Errorf("error leaks the request URL: %v", err)
+	}
+}
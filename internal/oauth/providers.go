@@ -0,0 +1,242 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"trinity_bot/internal/config"
+	"trinity_bot/internal/storage"
+)
+
+// Providers returns the providers whose OAuth apps are configured.
+func Providers(cfg *config.Config) []*Provider {
+	var out []*Provider
+	if cfg.TwitterClientID != "" {
+		out = append(out, Twitter(cfg.TwitterClientID, cfg.TwitterClientSecret))
+	}
+	if cfg.PinterestAppID != "" {
+		out = append(out, Pinterest(cfg.PinterestAppID, cfg.PinterestAppSecret))
+	}
+	if cfg.MetaAppID != "" {
+		out = append(out, Meta(cfg.MetaAppID, cfg.MetaAppSecret))
+	}
+	if cfg.LinkedInClientID != "" {
+		out = append(out, LinkedIn(cfg.LinkedInClientID, cfg.LinkedInClientSecret))
+	}
+	return out
+}
+
+// Twitter links the authorizing X/Twitter user through OAuth 2.0 with PKCE.
+func Twitter(clientID, clientSecret string) *Provider {
+	return &Provider{
+		Name:         "twitter",
+		AuthURL:      "https://twitter.com/i/oauth2/authorize",
+		TokenURL:     "https://api.twitter.com/2/oauth2/token",
+		APIBaseURL:   "https://api.twitter.com/2",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"tweet.read", "tweet.write", "users.read", "media.write", "offline.access"},
+		PKCE:         true,
+		BasicAuth:    clientSecret != "", // public clients send only client_id
+		accounts:     twitterAccounts,
+	}
+}
+
+func twitterAccounts(ctx context.Context, c *http.Client, p *Provider, tok *Token) ([]storage.Account, error) {
+	var me struct {
+		Data struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, c, p.APIBaseURL+"/users/me", tok.AccessToken, &me); err != nil {
+		return nil, fmt.Errorf("twitter user lookup: %w", err)
+	}
+	return []storage.Account{{
+		Platform:       "twitter",
+		Name:           "@" + me.Data.Username,
+		ExternalID:     me.Data.ID,
+		AccessToken:    tok.AccessToken,
+		RefreshToken:   tok.RefreshToken,
+		TokenExpiresAt: tok.ExpiresAt,
+	}}, nil
+}
+
+// Pinterest links the authorizing Pinterest user. Pinterest does not support PKCE.
+func Pinterest(appID, appSecret string) *Provider {
+	return &Provider{
+		Name:         "pinterest",
+		AuthURL:      "https://www.pinterest.com/oauth/",
+		TokenURL:     "https://api.pinterest.com/v5/oauth/token",
+		APIBaseURL:   "https://api.pinterest.com/v5",
+		ClientID:     appID,
+		ClientSecret: appSecret,
+		Scopes:       []string{"boards:read", "pins:read", "pins:write", "user_accounts:read"},
+		ScopeSep:     ",",
+		BasicAuth:    true,
+		accounts:     pinterestAccounts,
+	}
+}
+
+func pinterestAccounts(ctx context.Context, c *http.Client, p *Provider, tok *Token) ([]storage.Account, error) {
+	var me struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	}
+	if err := getJSON(ctx, c, p.APIBaseURL+"/user_account", tok.AccessToken, &me); err != nil {
+		return nil, fmt.Errorf("pinterest user lookup: %w", err)
+	}
+	id := me.ID
+	if id == "" {
+		id = me.Username
+	}
+	return []storage.Account{{
+		Platform:       "pinterest",
+		Name:           me.Username,
+		ExternalID:     id,
+		AccessToken:    tok.AccessToken,
+		RefreshToken:   tok.RefreshToken,
+		TokenExpiresAt: tok.ExpiresAt,
+	}}, nil
+}
+
+// Meta links the Facebook Pages the user manages and the Instagram professional accounts
+// connected to them.
+func Meta(appID, appSecret string) *Provider {
+	return &Provider{
+		Name:         "meta",
+		AuthURL:      "https://www.facebook.com/v19.0/dialog/oauth",
+		TokenURL:     "https://graph.facebook.com/v19.0/oauth/access_token",
+		APIBaseURL:   "https://graph.facebook.com/v19.0",
+		ClientID:     appID,
+		ClientSecret: appSecret,
+		Scopes: []string{"pages_show_list", "pages_read_engagement", "pages_manage_posts",
+			"instagram_basic", "instagram_content_publish", "business_management"},
+		ScopeSep: ",",
+		accounts: metaAccounts,
+		refresh: func(ctx context.Context, c *http.Client, p *Provider, a *storage.Account) (*Token, error) {
+			return exchangeMetaToken(ctx, c, p, a.AccessToken)
+		},
+	}
+}
+
+// metaAccounts trades the short-lived user token for a long-lived one (about 60 days). Pages get
+// their page token, which does not expire when obtained with a long-lived user token; Instagram
+// accounts publish with the long-lived user token.
+func metaAccounts(ctx context.Context, c *http.Client, p *Provider, tok *Token) ([]storage.Account, error) {
+	long, err := exchangeMetaToken(ctx, c, p, tok.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	var pages struct {
+		Data []struct {
+			ID          string `json:"id"`
+			Name        string `json:"name"`
+			AccessToken string `json:"access_token"`
+			Instagram   *struct {
+				ID       string `json:"id"`
+				Username string `json:"username"`
+			} `json:"instagram_business_account"`
+		} `json:"data"`
+	}
+	q := url.Values{}
+	q.Set("fields", "id,name,access_token,instagram_business_account{id,username}")
+	q.Set("limit", "100")
+	if err := getJSON(ctx, c, p.APIBaseURL+"/me/accounts?"+q.Encode(), long.AccessToken, &pages); err != nil {
+		return nil, fmt.Errorf("facebook pages lookup: %w", err)
+	}
+	var out []storage.Account
+	for _, pg := range pages.Data {
+		out = append(out, storage.Account{
+			Platform:    "facebook",
+			Name:        pg.Name,
+			ExternalID:  pg.ID,
+			AccessToken: pg.AccessToken,
+		})
+		if pg.Instagram != nil {
+			out = append(out, storage.Account{
+				Platform:       "instagram",
+				Name:           "@" + pg.Instagram.Username,
+				ExternalID:     pg.Instagram.ID,
+				AccessToken:    long.AccessToken,
+				TokenExpiresAt: long.ExpiresAt,
+			})
+		}
+	}
+	return out, nil
+}
+
+// exchangeMetaToken trades a user token for a long-lived one. Meta has no refresh tokens; a
+// long-lived token that is still valid is exchanged the same way for a new one. The app secret
+// and the token go in a POST form, not the URL.
+func exchangeMetaToken(ctx context.Context, c *http.Client, p *Provider, accessToken string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "fb_exchange_token")
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("fb_exchange_token", accessToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.APIBaseURL+"/oauth/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var out tokenResponse
+	if err := doJSON(c, req, &out); err != nil {
+		return nil, fmt.Errorf("meta token exchange: %w", err)
+	}
+	if out.ExpiresIn == 0 {
+		// Long-lived user tokens last about 60 days; Meta omits expires_in for some of them
+		out.ExpiresIn = int64((60 * 24 * time.Hour).Seconds())
+	}
+	return out.token(), nil
+}
+
+// LinkedIn links the organization pages the user administers. LinkedIn supports PKCE only for
+// native apps, so the client secret authenticates the exchange.
+func LinkedIn(clientID, clientSecret string) *Provider {
+	return &Provider{
+		Name:         "linkedin",
+		AuthURL:      "https://www.linkedin.com/oauth/v2/authorization",
+		TokenURL:     "https://www.linkedin.com/oauth/v2/accessToken",
+		APIBaseURL:   "https://api.linkedin.com/v2",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"w_organization_social", "r_organization_admin"},
+		accounts:     linkedInAccounts,
+	}
+}
+
+func linkedInAccounts(ctx context.Context, c *http.Client, p *Provider, tok *Token) ([]storage.Account, error) {
+	var acls struct {
+		Elements []struct {
+			Organization string `json:"organization"`
+			Details      *struct {
+				LocalizedName string `json:"localizedName"`
+			} `json:"organization~"`
+		} `json:"elements"`
+	}
+	q := "q=roleAssignee&role=ADMINISTRATOR&state=APPROVED&projection=(elements*(organization,organization~(localizedName)))"
+	if err := getJSON(ctx, c, p.APIBaseURL+"/organizationAcls?"+q, tok.AccessToken, &acls); err != nil {
+		return nil, fmt.Errorf("linkedin organizations lookup: %w", err)
+	}
+	var out []storage.Account
+	for _, e := range acls.Elements {
+		name := e.Organization
+		if e.Details != nil && e.Details.LocalizedName != "" {
+			name = e.Details.LocalizedName
+		}
+		out = append(out, storage.Account{
+			Platform:       "linkedin",
+			Name:           name,
+			ExternalID:     e.Organization,
+			AccessToken:    tok.AccessToken,
+			RefreshToken:   tok.RefreshToken,
+			TokenExpiresAt: tok.ExpiresAt,
+		})
+	}
+	return out, nil
+}
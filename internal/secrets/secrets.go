@@ -0,0 +1,195 @@
+// Package secrets encrypts credentials at rest with envelope encryption: every value gets its own
+// random data key (AES-256-GCM), and the data key is sealed with a master key. Master keys carry
+// IDs, so a new key can become active while values sealed with older ones stay readable until
+// they are re-encrypted.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks encrypted values; values without it are legacy plain text.
+const prefix = "enc:v1:"
+
+const keySize = 32 // AES-256
+
+// ErrNoKey is returned when a value has to be encrypted but no master key is configured.
+var ErrNoKey = errors.New("no master key configured (set SECRETS_MASTER_KEYS or SECRETS_MASTER_KEY_FILE)")
+
+// Keyring holds the master keys. The first key is active and seals new values; the others only
+// open values sealed before a rotation.
+type Keyring struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// ParseKeyring parses master keys given as "<id>:<base64 key>" entries separated by commas or
+// newlines, active key first. Keys are 32 bytes. An empty spec returns a nil keyring, which
+// reads plain text but cannot encrypt.
+func ParseKeyring(spec string) (*Keyring, error) {
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' })
+	var kr *Keyring
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" || strings.HasPrefix(f, "#") {
+			continue
+		}
+		id, enc, ok := strings.Cut(f, ":")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid master key entry %q: want <id>:<base64 key>", redact(f))
+		}
+		raw, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil || len(raw) != keySize {
+			return nil, fmt.Errorf("master key %q must be %d bytes, base64-encoded", id, keySize)
+		}
+		aead, err := newAEAD(raw)
+		if err != nil {
+			return nil, err
+		}
+		if kr == nil {
+			kr = &Keyring{active: id, keys: map[string]cipher.AEAD{}}
+		}
+		if _, dup := kr.keys[id]; dup {
+			return nil, fmt.Errorf("duplicate master key id %q", id)
+		}
+		kr.keys[id] = aead
+	}
+	return kr, nil
+}
+
+// ActiveKeyID returns the ID of the key that seals new values.
+func (k *Keyring) ActiveKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.active
+}
+
+// Encrypt seals a value under the active key. context is authenticated with it (e.g. the column
+// name), so a sealed value cannot be moved to another column. Empty values stay empty.
+func (k *Keyring) Encrypt(plaintext, context string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	if k == nil {
+		return "", ErrNoKey
+	}
+	dek := make([]byte, keySize)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := seal(data, []byte(plaintext), []byte(context))
+	if err != nil {
+		return "", err
+	}
+	sealedKey, err := seal(k.keys[k.active], dek, []byte(k.active))
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return prefix + k.active + ":" + enc.EncodeToString(sealedKey) + ":" + enc.EncodeToString(sealedValue), nil
+}
+
+// Decrypt opens a value sealed by Encrypt with the same context. Plain text values are returned
+// as they are.
+func (k *Keyring) Decrypt(value, context string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	id := parts[0]
+	if k == nil {
+		return "", fmt.Errorf("value is sealed with master key %q: %w", id, ErrNoKey)
+	}
+	master, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("master key %q not configured", id)
+	}
+	enc := base64.RawStdEncoding
+	sealedKey, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	sealedValue, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	dek, err := open(master, sealedKey, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("unseal data key with master key %q: %w", id, err)
+	}
+	data, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, sealedValue, []byte(context))
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether a stored value was sealed by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID returns the ID of the master key a value is sealed with, or "" for plain text.
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id
+}
+
+// NeedsReencrypt reports whether a stored value is not sealed with the active key.
+func (k *Keyring) NeedsReencrypt(value string) bool {
+	return value != "" && KeyID(value) != k.ActiveKeyID()
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, which is prepended to the ciphertext.
+func seal(aead cipher.AEAD, plaintext, ad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, ad), nil
+}
+
+func open(aead cipher.AEAD, sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, ad)
+}
+
+// redact hides the key material of a malformed entry in error messages.
+func redact(entry string) string {
+	if id, _, ok := strings.Cut(entry, ":"); ok {
+		return id + ":…"
+	}
+	return "…"
+}
@@ -0,0 +1,154 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) string {
+	t.Helper()
+	k := make([]byte, keySize)
+	if _, err := rand.Read(k); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(k)
+}
+
+func mustKeyring(t *testing.T, spec string) *Keyring {
+	t.Helper()
+	kr, err := ParseKeyring(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+func TestRoundTrip(t *testing.T) {
+	kr := mustKeyring(t, "k1:"+newKey(t))
+	enc, err := kr.Encrypt("token-123", "platform_accounts.access_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(enc) || KeyID(enc) != "k1" || strings.Contains(enc, "token-123") {
+		t.Errorf("encrypted value = %q", enc)
+	}
+	again, _ := kr.Encrypt("token-123", "platform_accounts.access_token")
+	if again == enc {
+		t.Error("encrypting twice gives the same value")
+	}
+	plain, err := kr.Decrypt(enc, "platform_accounts.access_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != "token-123" {
+		t.Errorf("decrypted = %q", plain)
+	}
+
+	// Empty values stay empty, plain text passes through
+	if enc, err := kr.Encrypt("", "c"); err != nil || enc != "" {
+		t.Errorf("Encrypt(\"\") = %q, %v", enc, err)
+	}
+	if plain, err := kr.Decrypt("legacy", "c"); err != nil || plain != "legacy" {
+		t.Errorf("Decrypt(plain) = %q, %v", plain, err)
+	}
+}
+
+func TestWrongContext(t *testing.T) {
+	kr := mustKeyring(t, "k1:"+newKey(t))
+	enc, err := kr.Encrypt("secret", "platform_accounts.access_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kr.Decrypt(enc, "platform_accounts.refresh_token"); err == nil {
+		t.Error("value decrypted under another column")
+	}
+}
+
+func TestTampered(t *testing.T) {
+	kr := mustKeyring(t, "k1:"+newKey(t))
+	enc, _ := kr.Encrypt("secret", "c")
+	parts := strings.Split(enc, ":")
+	last := []byte(parts[len(parts)-1])
+	last[0] ^= 1 // stays a non-separator character
+	parts[len(parts)-1] = string(last)
+	if _, err := kr.Decrypt(strings.Join(parts, ":"), "c"); err == nil {
+		t.Error("tampered value decrypted")
+	}
+	if _, err := kr.Decrypt("enc:v1:k1:broken", "c"); err == nil {
+		t.Error("malformed value decrypted")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	k1, k2 := newKey(t), newKey(t)
+	old := mustKeyring(t, "k1:"+k1)
+	enc1, _ := old.Encrypt("secret", "c")
+
+	// k2 becomes active; k1 still opens older values
+	rotated := mustKeyring(t, "k2:"+k2+",k1:"+k1)
+	if rotated.ActiveKeyID() != "k2" {
+		t.Errorf("active key = %q", rotated.ActiveKeyID())
+	}
+	if plain, err := rotated.Decrypt(enc1, "c"); err != nil || plain != "secret" {
+		t.Errorf("Decrypt(old value) = %q, %v", plain, err)
+	}
+	if !rotated.NeedsReencrypt(enc1) || !rotated.NeedsReencrypt("plain") || rotated.NeedsReencrypt("") {
+		t.Error("NeedsReencrypt misreports old, plain or empty values")
+	}
+	enc2, _ := rotated.Encrypt("secret", "c")
+	if KeyID(enc2) != "k2" || rotated.NeedsReencrypt(enc2) {
+		t.Errorf("new value sealed with %q", KeyID(enc2))
+	}
+
+	// Once k1 is removed its values can no longer be opened
+	only2 := mustKeyring(t, "k2:"+k2)
+	if _, err := only2.Decrypt(enc1, "c"); err == nil || !strings.Contains(err.Error(), `"k1"`) {
+		t.Errorf("Decrypt with removed key: %v", err)
+	}
+	if plain, err := only2.Decrypt(enc2, "c"); err != nil || plain != "secret" {
+		t.Errorf("Decrypt(new value) = %q, %v", plain, err)
+	}
+}
+
+func TestNilKeyring(t *testing.T) {
+	var kr *Keyring
+	if _, err := kr.Encrypt("secret", "c"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Encrypt without keys: %v", err)
+	}
+	if plain, err := kr.Decrypt("legacy", "c"); err != nil || plain != "legacy" {
+		t.Errorf("Decrypt(plain) without keys = %q, %v", plain, err)
+	}
+	if _, err := kr.Decrypt("enc:v1:k1:a:b", "c"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Decrypt(sealed) without keys: %v", err)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	k := newKey(t)
+	if kr := mustKeyring(t, ""); kr != nil {
+		t.Error("empty spec returned a keyring")
+	}
+	kr := mustKeyring(t, "# comment\nk2:"+k+"\n\nk1:"+newKey(t)+"\n")
+	if kr.ActiveKeyID() != "k2" || len(kr.keys) != 2 {
+		t.Errorf("parsed %d keys, active %q", len(kr.keys), kr.ActiveKeyID())
+	}
+	for _, spec := range []string{
+		"nokey",
+		":" + k,
+		"k1:not-base64!",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"k1:" + k + ",k1:" + k,
+	} {
+		_, err := ParseKeyring(spec)
+		if err == nil {
+			t.Errorf("ParseKeyring(%q) accepted", spec)
+			continue
+		}
+		if strings.Contains(err.Error(), k) {
+			t.Errorf("error leaks key material: %v", err)
+		}
+	}
+}
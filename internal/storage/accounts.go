@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"trinity_bot/internal/secrets"
+)
+
+// Account is a platform account posts can be published to, e.g. one of several Facebook Pages.
+// Targets without an account use the platform's credentials from the environment.
+type Account struct {
+	ID             int64
+	Platform       string
+	Name           string // shown on the target keyboard
+	ExternalID     string // page/user/organization/channel ID or handle on the platform
+	AccessToken    string
+	AccessSecret   string // OAuth 1.0a token secret (Twitter) or app password (Bluesky)
+	RefreshToken   string
+	TokenExpiresAt *time.Time
+	ExpiryWarnedAt *time.Time        // when the owner was warned that the token expires
+	Settings       map[string]string // non-secret options, e.g. "instance_url", "board_id"
+	OwnerUserID    int64             // Telegram user who added the account
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// AccountRepository stores platform accounts.
+type AccountRepository interface {
+	SaveAccount(ctx context.Context, a *Account) (int64, error)
+	GetAccount(ctx context.Context, id int64) (*Account, error)
+	ListAccounts(ctx context.Context, platform string, ownerUserID int64) ([]Account, error)
+	UpdateAccountTokens(ctx context.Context, id int64, accessToken, refreshToken string, expiresAt *time.Time) error
+	ListExpiringAccounts(ctx context.Context, before time.Time) ([]Account, error)
+	MarkExpiryWarned(ctx context.Context, id int64) error
+	ReencryptAccounts(ctx context.Context) (int, error)
+	DeleteAccount(ctx context.Context, id int64, ownerUserID int64) error
+}
+
+// ErrAccountInUse is returned when deleting an account that targets still reference.
+var ErrAccountInUse = errors.New("account is referenced by posts")
+
+// ErrAccountOwned is returned by SaveAccount when the account was connected by another user.
+var ErrAccountOwned = errors.New("account belongs to another user")
+
+// errUndecryptable marks accounts whose secrets cannot be decrypted, e.g. because their master key
+// was removed from the keyring.
+var errUndecryptable = errors.New("cannot decrypt account secrets")
+
+// NewAccounts returns the account repository. Tokens and secrets are encrypted with keys; with a
+// nil keyring only accounts without secrets can be saved.
+func NewAccounts(db *sql.DB, keys *secrets.Keyring) AccountRepository {
+	return &repo{db: db, keys: keys}
+}
+
+// secretColumns are the encrypted columns of platform_accounts. The column name is authenticated
+// with each value.
+var secretColumns = [...]string{"access_token", "access_secret", "refresh_token"}
+
+// sealSecrets encrypts an account's secrets in secretColumns order.
+func (r *repo) sealSecrets(values ...string) ([]string, error) {
+	out := make([]string, len(values))
+	for i, v := range values {
+		enc, err := r.keys.Encrypt(v, "platform_accounts."+secretColumns[i])
+		if err != nil {
+			return nil, fmt.Errorf("encrypt %s: %w", secretColumns[i], err)
+		}
+		out[i] = enc
+	}
+	return out, nil
+}
+
+// openSecrets decrypts an account's secrets in place, in secretColumns order.
+func (r *repo) openSecrets(id int64, values ...*string) error {
+	for i, v := range values {
+		plain, err := r.keys.Decrypt(*v, "platform_accounts."+secretColumns[i])
+		if err != nil {
+			return fmt.Errorf("account %d %s: %w: %w", id, secretColumns[i], errUndecryptable, err)
+		}
+		*v = plain
+	}
+	return nil
+}
+
+// TargetKey identifies a selectable target: the platform alone for the environment's
+// credentials, or "<platform>:<account id>".
+func TargetKey(platform string, accountID *int64) string {
+	if accountID == nil {
+		return platform
+	}
+	return platform + ":" + strconv.FormatInt(*accountID, 10)
+}
+
+// ParseTargetKey splits a key built by TargetKey.
+func ParseTargetKey(key string) (platform string, accountID *int64, err error) {
+	platform, id, ok := strings.Cut(key, ":")
+	if !ok {
+		return platform, nil, nil
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid target %q", key)
+	}
+	return platform, &n, nil
+}
+
+const accountColumns = `id, platform, name, external_id, access_token, access_secret, refresh_token, token_expires_at, expiry_warned_at, settings, owner_user_id, created_at, updated_at`
+
+func (r *repo) scanAccount(row rowScanner) (*Account, error) {
+	var a Account
+	var expires, warned sql.NullTime
+	var settings []byte
+	if err := row.Scan(&a.ID, &a.Platform, &a.Name, &a.ExternalID, &a.AccessToken, &a.AccessSecret, &a.RefreshToken, &expires, &warned, &settings, &a.OwnerUserID, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if expires.Valid {
+		v := expires.Time
+		a.TokenExpiresAt = &v
+	}
+	if warned.Valid {
+		v := warned.Time
+		a.ExpiryWarnedAt = &v
+	}
+	if err := json.Unmarshal(settings, &a.Settings); err != nil {
+		return nil, fmt.Errorf("account %d settings: %w", a.ID, err)
+	}
+	if err := r.openSecrets(a.ID, &a.AccessToken, &a.AccessSecret, &a.RefreshToken); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// scanAccounts reads the accounts of a query. Accounts whose secrets cannot be decrypted are
+// logged and skipped, so one of them does not break every list; GetAccount still reports them.
+func (r *repo) scanAccounts(rows *sql.Rows) ([]Account, error) {
+	var out []Account
+	for rows.Next() {
+		a, err := r.scanAccount(rows)
+		if errors.Is(err, errUndecryptable) {
+			slog.Error("Skipping account with undecryptable secrets", "err", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *a)
+	}
+	return out, rows.Err()
+}
+
+// SaveAccount inserts an account, or updates the existing account with the same platform and
+// external ID (e.g. when it is connected again). An account owned by another user is left
+// unchanged and ErrAccountOwned returned; accounts without an owner are taken over. Returns the
+// account ID.
+func (r *repo) SaveAccount(ctx context.Context, a *Account) (int64, error) {
+	if a == nil {
+		return 0, errors.New("nil account")
+	}
+	platform := strings.ToLower(a.Platform)
+	if !validPlatform(platform) {
+		return 0, fmt.Errorf("invalid platform: %s", a.Platform)
+	}
+	settings := a.Settings
+	if settings == nil {
+		settings = map[string]string{}
+	}
+	rawSettings, err := json.Marshal(settings)
+	if err != nil {
+		return 0, fmt.Errorf("encode account settings: %w", err)
+	}
+	sealed, err := r.sealSecrets(a.AccessToken, a.AccessSecret, a.RefreshToken)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = r.db.QueryRowContext(ctx, `
+        INSERT INTO platform_accounts(platform, name, external_id, access_token, access_secret, refresh_token, token_expires_at, settings, owner_user_id)
+        VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+        ON CONFLICT (platform, external_id) DO UPDATE SET name=EXCLUDED.name, access_token=EXCLUDED.access_token,
+            access_secret=EXCLUDED.access_secret, refresh_token=EXCLUDED.refresh_token, token_expires_at=EXCLUDED.token_expires_at,
+            settings=EXCLUDED.settings, owner_user_id=EXCLUDED.owner_user_id, expiry_warned_at=NULL, updated_at=NOW()
+        WHERE platform_accounts.owner_user_id IN (0, EXCLUDED.owner_user_id)
+        RETURNING id
+    `, platform, a.Name, a.ExternalID, sealed[0], sealed[1], sealed[2], a.TokenExpiresAt, rawSettings, a.OwnerUserID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrAccountOwned
+	}
+	if err != nil {
+		return 0, fmt.Errorf("save account: %w", err)
+	}
+	return id, nil
+}
+
+func (r *repo) GetAccount(ctx context.Context, id int64) (*Account, error) {
+	a, err := r.scanAccount(r.db.QueryRowContext(ctx, `SELECT `+accountColumns+` FROM platform_accounts WHERE id=$1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("account %d not found", id)
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+// ListAccounts returns the accounts of a platform, or of all platforms when platform is empty,
+// ordered by platform and name. A non-zero ownerUserID limits them to that user's accounts.
+func (r *repo) ListAccounts(ctx context.Context, platform string, ownerUserID int64) ([]Account, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+accountColumns+` FROM platform_accounts
+        WHERE ($1 = '' OR platform = $1) AND ($2 = 0 OR owner_user_id = $2) ORDER BY platform, name, id`, strings.ToLower(platform), ownerUserID)
+	if err != nil {
+		return nil, fmt.Errorf("list accounts: %w", err)
+	}
+	defer rows.Close()
+	return r.scanAccounts(rows)
+}
+
+// UpdateAccountTokens replaces an account's tokens, e.g. after a refresh.
+func (r *repo) UpdateAccountTokens(ctx context.Context, id int64, accessToken, refreshToken string, expiresAt *time.Time) error {
+	sealed, err := r.sealSecrets(accessToken, "", refreshToken)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, `UPDATE platform_accounts SET access_token=$2, refresh_token=$3, token_expires_at=$4, expiry_warned_at=NULL, updated_at=NOW()
+        WHERE id=$1`, id, sealed[0], sealed[2], expiresAt)
+	if err != nil {
+		return fmt.Errorf("update account tokens: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("update account tokens: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("account %d not found", id)
+	}
+	return nil
+}
+
+// ListExpiringAccounts returns the accounts whose tokens expire before the given time, including
+// expired ones, soonest first.
+func (r *repo) ListExpiringAccounts(ctx context.Context, before time.Time) ([]Account, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+accountColumns+` FROM platform_accounts
+        WHERE token_expires_at IS NOT NULL AND token_expires_at < $1 ORDER BY token_expires_at`, before)
+	if err != nil {
+		return nil, fmt.Errorf("list expiring accounts: %w", err)
+	}
+	defer rows.Close()
+	return r.scanAccounts(rows)
+}
+
+// MarkExpiryWarned records that the owner was warned about the account's expiring token.
+func (r *repo) MarkExpiryWarned(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE platform_accounts SET expiry_warned_at=NOW() WHERE id=$1`, id); err != nil {
+		return fmt.Errorf("mark expiry warned: %w", err)
+	}
+	return nil
+}
+
+// ReencryptAccounts seals every secret that is stored in plain text or under a master key other
+// than the active one with the active key. Returns the number of accounts rewritten. Run it after
+// making a new key active, before removing the old one.
+func (r *repo) ReencryptAccounts(ctx context.Context) (int, error) {
+	if r.keys == nil {
+		return 0, secrets.ErrNoKey
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin re-encrypt: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, access_token, access_secret, refresh_token FROM platform_accounts ORDER BY id FOR UPDATE`)
+	if err != nil {
+		return 0, fmt.Errorf("select accounts: %w", err)
+	}
+	type stored struct {
+		id     int64
+		values [3]string
+	}
+	var stale []stored
+	for rows.Next() {
+		var s stored
+		if err := rows.Scan(&s.id, &s.values[0], &s.values[1], &s.values[2]); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		for _, v := range s.values {
+			if r.keys.NeedsReencrypt(v) {
+				stale = append(stale, s)
+				break
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, s := range stale {
+		if err := r.openSecrets(s.id, &s.values[0], &s.values[1], &s.values[2]); err != nil {
+			return 0, err
+		}
+		sealed, err := r.sealSecrets(s.values[0], s.values[1], s.values[2])
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE platform_accounts SET access_token=$2, access_secret=$3, refresh_token=$4 WHERE id=$1`,
+			s.id, sealed[0], sealed[1], sealed[2]); err != nil {
+			return 0, fmt.Errorf("update account %d: %w", s.id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit re-encrypt: %w", err)
+	}
+	return len(stale), nil
+}
+
+// DeleteAccount deletes an account together with its unpublished selections. Accounts with
+// publication history are kept and ErrAccountInUse is returned. A non-zero ownerUserID only
+// deletes an account of that user; others are reported as not found.
+func (r *repo) DeleteAccount(ctx context.Context, id int64, ownerUserID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin delete account: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var owner int64
+	if err := tx.QueryRowContext(ctx, `SELECT owner_user_id FROM platform_accounts WHERE id=$1 FOR UPDATE`, id).Scan(&owner); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("account %d not found", id)
+		}
+		return fmt.Errorf("delete account: %w", err)
+	}
+	if ownerUserID != 0 && owner != ownerUserID {
+		return fmt.Errorf("account %d not found", id)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_targets WHERE account_id=$1 AND status='pending'`, id); err != nil {
+		return fmt.Errorf("delete account targets: %w", err)
+	}
+	var inUse bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM post_targets WHERE account_id=$1)`, id).Scan(&inUse); err != nil {
+		return fmt.Errorf("delete account: %w", err)
+	}
+	if inUse {
+		return ErrAccountInUse
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM platform_accounts WHERE id=$1`, id)
+	if err != nil {
+		return fmt.Errorf("delete account: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("delete account: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("account %d not found", id)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete account: %w", err)
+	}
+	return nil
+}
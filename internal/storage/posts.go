@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"trinity_bot/internal/secrets"
 )
 
 // Platforms supported
-var Platforms = []string{"twitter", "pinterest", "facebook", "instagram", "tiktok"}
+var Platforms = []string{"twitter", "pinterest", "facebook", "instagram", "tiktok", "mastodon", "bluesky", "telegram_channel", "linkedin", "threads"}
 
 func validPlatform(p string) bool {
 	p = strings.ToLower(p)
@@ -31,27 +33,46 @@ type Post struct {
 	TextContent    string
 	PhotoFileID    *string
 	Status         string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	ScheduledAt    *time.Time
+	Link           string // destination URL, may be empty
+	// Pinterest board and section chosen for the post; empty means the configured default board
+	PinterestBoardID   string
+	PinterestSectionID string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
 }
 
 type PostRepository interface {
 	CreatePost(ctx context.Context, p *Post) (int64, error)
-	ToggleTarget(ctx context.Context, postID int64, platform string) (bool, error)
+	ToggleTarget(ctx context.Context, postID int64, platform string, accountID *int64, ownerUserID int64) (bool, error)
 	ListTargets(ctx context.Context, postID int64) (map[string]bool, error)
 	SetPostStatus(ctx context.Context, postID int64, status string) error
 	GetPost(ctx context.Context, id int64) (*Post, error)
-	SetTargetStatus(ctx context.Context, postID int64, platform string, status string, externalID *string, errText *string) error
+	SetTargetStatus(ctx context.Context, targetID int64, status string, externalID *string, errText *string) error
 	AddLog(ctx context.Context, postID int64, platform *string, event, detail string) error
 	AddMedia(ctx context.Context, postID int64, fileID string, mediaType string) (int64, error)
 	ListMedia(ctx context.Context, postID int64) ([]PostMedia, error)
 	CountMedia(ctx context.Context, postID int64) (int, error)
+	SetMediaDescription(ctx context.Context, postID int64, position int, description string) error
 	UpdatePostText(ctx context.Context, postID int64, text string) error
 	AppendPostText(ctx context.Context, postID int64, text string) error
+	SetPostLink(ctx context.Context, postID int64, link string) error
+	SetPinterestBoard(ctx context.Context, postID int64, boardID, sectionID string) error
+	EnqueuePost(ctx context.Context, postID int64) (int, error)
+	QueueTarget(ctx context.Context, targetID int64) error
+	ClaimTargets(ctx context.Context, limit int, lease time.Duration) ([]Target, error)
+	ListPostTargets(ctx context.Context, postID int64) ([]Target, error)
+	RetryTarget(ctx context.Context, targetID int64, at time.Time, errText string) error
+	FinishPost(ctx context.Context, postID int64, status string) (bool, error)
+	SchedulePost(ctx context.Context, postID int64, at time.Time) error
+	UnschedulePost(ctx context.Context, postID int64) error
+	ListScheduledPosts(ctx context.Context, chatID int64) ([]Post, error)
+	ReleaseDuePosts(ctx context.Context) ([]Post, error)
 }
 
 type repo struct {
-	db *sql.DB
+	db   *sql.DB
+	keys *secrets.Keyring // seals platform account secrets; see accounts.go
 }
 
 func New(db *sql.DB) PostRepository {
@@ -73,8 +94,11 @@ func (r *repo) CreatePost(ctx context.Context, p *Post) (int64, error) {
 	return id, nil
 }
 
-// ToggleTarget inserts or deletes a selection row. Returns true if enabled after toggle.
-func (r *repo) ToggleTarget(ctx context.Context, postID int64, platform string) (bool, error) {
+// ToggleTarget inserts or deletes a selection row for a platform account (nil for the
+// environment's credentials). A non-zero ownerUserID only allows selecting that user's accounts.
+// The Pinterest board is stored per post, so a post has at most one Pinterest target: selecting
+// another one replaces it and clears the chosen board. Returns true if enabled after toggle.
+func (r *repo) ToggleTarget(ctx context.Context, postID int64, platform string, accountID *int64, ownerUserID int64) (bool, error) {
 	platform = strings.ToLower(platform)
 	if !validPlatform(platform) {
 		return false, fmt.Errorf("invalid platform: %s", platform)
@@ -82,39 +106,75 @@ func (r *repo) ToggleTarget(ctx context.Context, postID int64, platform string)
 
 	// Check if exists
 	var exists bool
-	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM post_targets WHERE post_id=$1 AND platform=$2)`, postID, platform).Scan(&exists)
+	err := r.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM post_targets WHERE post_id=$1 AND platform=$2 AND account_id IS NOT DISTINCT FROM $3)`,
+		postID, platform, accountID).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("select exists: %w", err)
 	}
 
 	if exists {
-		if _, err := r.db.ExecContext(ctx, `DELETE FROM post_targets WHERE post_id=$1 AND platform=$2`, postID, platform); err != nil {
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM post_targets WHERE post_id=$1 AND platform=$2 AND account_id IS NOT DISTINCT FROM $3`, postID, platform, accountID); err != nil {
 			return false, fmt.Errorf("delete target: %w", err)
 		}
 		return false, nil
 	}
-	if _, err := r.db.ExecContext(ctx, `INSERT INTO post_targets(post_id, platform, status) VALUES ($1,$2,'pending')`, postID, platform); err != nil {
+	if accountID != nil {
+		var accountPlatform string
+		var owner int64
+		if err := r.db.QueryRowContext(ctx, `SELECT platform, owner_user_id FROM platform_accounts WHERE id=$1`, *accountID).Scan(&accountPlatform, &owner); err != nil {
+			return false, fmt.Errorf("account %d: %w", *accountID, err)
+		}
+		if ownerUserID != 0 && owner != ownerUserID {
+			return false, fmt.Errorf("account %d not found", *accountID)
+		}
+		if accountPlatform != platform {
+			return false, fmt.Errorf("account %d is not a %s account", *accountID, platform)
+		}
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin toggle target: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if platform == "pinterest" {
+		res, err := tx.ExecContext(ctx, `DELETE FROM post_targets WHERE post_id=$1 AND platform='pinterest' AND status='pending'`, postID)
+		if err != nil {
+			return false, fmt.Errorf("replace pinterest target: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			if _, err := tx.ExecContext(ctx, `UPDATE posts SET pinterest_board_id='', pinterest_section_id='', updated_at=NOW() WHERE id=$1`, postID); err != nil {
+				return false, fmt.Errorf("clear pinterest board: %w", err)
+			}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO post_targets(post_id, platform, account_id, status) VALUES ($1,$2,$3,'pending')`, postID, platform, accountID); err != nil {
 		return false, fmt.Errorf("insert target: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit toggle target: %w", err)
+	}
 	return true, nil
 }
 
+// ListTargets returns the selected targets of a post keyed by TargetKey.
 func (r *repo) ListTargets(ctx context.Context, postID int64) (map[string]bool, error) {
 	selected := make(map[string]bool, len(Platforms))
-	for _, p := range Platforms {
-		selected[p] = false
-	}
-	rows, err := r.db.QueryContext(ctx, `SELECT platform FROM post_targets WHERE post_id=$1`, postID)
+	rows, err := r.db.QueryContext(ctx, `SELECT platform, account_id FROM post_targets WHERE post_id=$1`, postID)
 	if err != nil {
 		return nil, fmt.Errorf("list targets: %w", err)
 	}
 	defer rows.Close()
 	for rows.Next() {
 		var p string
-		if err := rows.Scan(&p); err != nil {
+		var account sql.NullInt64
+		if err := rows.Scan(&p, &account); err != nil {
 			return nil, err
 		}
-		selected[strings.ToLower(p)] = true
+		var accountID *int64
+		if account.Valid {
+			accountID = &account.Int64
+		}
+		selected[TargetKey(strings.ToLower(p), accountID)] = true
 	}
 	return selected, rows.Err()
 }
@@ -127,29 +187,44 @@ func (r *repo) SetPostStatus(ctx context.Context, postID int64, status string) e
 	return nil
 }
 
-func (r *repo) GetPost(ctx context.Context, id int64) (*Post, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id, telegram_user_id, chat_id, message_id, type, text_content, photo_file_id, status, created_at, updated_at FROM posts WHERE id=$1`, id)
+const postColumns = `id, telegram_user_id, chat_id, message_id, type, text_content, photo_file_id, status, scheduled_at, link, pinterest_board_id, pinterest_section_id, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row rowScanner) (*Post, error) {
 	var p Post
 	var photo sql.NullString
-	if err := row.Scan(&p.ID, &p.TelegramUserID, &p.ChatID, &p.MessageID, &p.Type, &p.TextContent, &photo, &p.Status, &p.CreatedAt, &p.UpdatedAt); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, fmt.Errorf("post %d not found", id)
-		}
+	var scheduled sql.NullTime
+	if err := row.Scan(&p.ID, &p.TelegramUserID, &p.ChatID, &p.MessageID, &p.Type, &p.TextContent, &photo, &p.Status, &scheduled, &p.Link, &p.PinterestBoardID, &p.PinterestSectionID, &p.CreatedAt, &p.UpdatedAt); err != nil {
 		return nil, err
 	}
 	if photo.Valid {
 		v := photo.String
 		p.PhotoFileID = &v
 	}
+	if scheduled.Valid {
+		v := scheduled.Time
+		p.ScheduledAt = &v
+	}
 	return &p, nil
 }
 
-func (r *repo) SetTargetStatus(ctx context.Context, postID int64, platform string, status string, externalID *string, errText *string) error {
-	// upsert target row
-	_, err := r.db.ExecContext(ctx, `INSERT INTO post_targets (post_id, platform, status, external_post_id, error)
-        VALUES ($1,$2,$3,$4,$5)
-        ON CONFLICT (post_id, platform) DO UPDATE SET status=EXCLUDED.status, external_post_id=EXCLUDED.external_post_id, error=EXCLUDED.error, updated_at=NOW()`,
-		postID, strings.ToLower(platform), status, externalID, errText)
+func (r *repo) GetPost(ctx context.Context, id int64) (*Post, error) {
+	p, err := scanPost(r.db.QueryRowContext(ctx, `SELECT `+postColumns+` FROM posts WHERE id=$1`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("post %d not found", id)
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *repo) SetTargetStatus(ctx context.Context, targetID int64, status string, externalID *string, errText *string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE post_targets SET status=$2, external_post_id=$3, error=$4, updated_at=NOW() WHERE id=$1`,
+		targetID, status, externalID, errText)
 	if err != nil {
 		return fmt.Errorf("set target status: %w", err)
 	}
@@ -165,11 +240,12 @@ func (r *repo) AddLog(ctx context.Context, postID int64, platform *string, event
 }
 
 type PostMedia struct {
-	ID       int64
-	PostID   int64
-	FileID   string
-	Type     string
-	Position int
+	ID          int64
+	PostID      int64
+	FileID      string
+	Type        string
+	Position    int
+	Description string // alt text
 }
 
 func (r *repo) AddMedia(ctx context.Context, postID int64, fileID string, mediaType string) (int64, error) {
@@ -190,7 +266,7 @@ func (r *repo) AddMedia(ctx context.Context, postID int64, fileID string, mediaT
 }
 
 func (r *repo) ListMedia(ctx context.Context, postID int64) ([]PostMedia, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, post_id, file_id, media_type, position FROM post_media WHERE post_id=$1 ORDER BY position ASC`, postID)
+	rows, err := r.db.QueryContext(ctx, `SELECT id, post_id, file_id, media_type, position, description FROM post_media WHERE post_id=$1 ORDER BY position ASC`, postID)
 	if err != nil {
 		return nil, fmt.Errorf("list media: %w", err)
 	}
@@ -198,7 +274,7 @@ func (r *repo) ListMedia(ctx context.Context, postID int64) ([]PostMedia, error)
 	var out []PostMedia
 	for rows.Next() {
 		var m PostMedia
-		if err := rows.Scan(&m.ID, &m.PostID, &m.FileID, &m.Type, &m.Position); err != nil {
+		if err := rows.Scan(&m.ID, &m.PostID, &m.FileID, &m.Type, &m.Position, &m.Description); err != nil {
 			return nil, err
 		}
 		out = append(out, m)
@@ -214,6 +290,20 @@ func (r *repo) CountMedia(ctx context.Context, postID int64) (int, error) {
 	return c, nil
 }
 
+// SetMediaDescription sets the alt text of the attachment at position (0-based).
+func (r *repo) SetMediaDescription(ctx context.Context, postID int64, position int, description string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE post_media SET description=$3 WHERE post_id=$1 AND position=$2`, postID, position, description)
+	if err != nil {
+		return fmt.Errorf("set media description: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("set media description: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("media %d of post %d not found", position+1, postID)
+	}
+	return nil
+}
+
 func (r *repo) UpdatePostText(ctx context.Context, postID int64, text string) error {
 	_, err := r.db.ExecContext(ctx, `UPDATE posts SET text_content=$2, updated_at=NOW() WHERE id=$1`, postID, text)
 	if err != nil {
@@ -229,3 +319,21 @@ func (r *repo) AppendPostText(ctx context.Context, postID int64, text string) er
 	}
 	return nil
 }
+
+// SetPostLink sets the destination URL of a post; an empty link removes it.
+func (r *repo) SetPostLink(ctx context.Context, postID int64, link string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE posts SET link=$2, updated_at=NOW() WHERE id=$1`, postID, link)
+	if err != nil {
+		return fmt.Errorf("set post link: %w", err)
+	}
+	return nil
+}
+
+// SetPinterestBoard sets the Pinterest board and (optional) section a post is pinned to.
+func (r *repo) SetPinterestBoard(ctx context.Context, postID int64, boardID, sectionID string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE posts SET pinterest_board_id=$2, pinterest_section_id=$3, updated_at=NOW() WHERE id=$1`, postID, boardID, sectionID)
+	if err != nil {
+		return fmt.Errorf("set pinterest board: %w", err)
+	}
+	return nil
+}
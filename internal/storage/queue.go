@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Target is a single post/platform publication tracked in post_targets.
+type Target struct {
+	ID             int64
+	PostID         int64
+	Platform       string
+	AccountID      *int64 // platform account; nil for the environment's credentials
+	Status         string
+	ExternalPostID *string
+	Error          *string
+	Attempts       int        // publish attempts so far, including the current one once claimed
+	NextRetryAt    *time.Time // earliest time a queued retry may be claimed
+}
+
+const targetColumns = `id, post_id, platform, account_id, status, external_post_id, error, attempts, next_retry_at`
+
+// EnqueuePost marks the post and all of its selected, not yet published targets as queued
+// so the publish worker picks them up. Returns the number of queued targets; when it is zero
+// the post is left untouched.
+func (r *repo) EnqueuePost(ctx context.Context, postID int64) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin enqueue: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `UPDATE post_targets SET status='queued', error=NULL, locked_at=NULL, attempts=0, next_retry_at=NULL, updated_at=NOW()
+        WHERE post_id=$1 AND status IN ('pending','queued','failed')`, postID)
+	if err != nil {
+		return 0, fmt.Errorf("queue targets: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("queue targets: %w", err)
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE posts SET status='queued', updated_at=NOW() WHERE id=$1`, postID); err != nil {
+		return 0, fmt.Errorf("queue post: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit enqueue: %w", err)
+	}
+	return int(n), nil
+}
+
+// QueueTarget queues a single not yet published target, e.g. to hand a scheduled post over to a
+// platform's own scheduling ahead of time.
+func (r *repo) QueueTarget(ctx context.Context, targetID int64) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE post_targets SET status='queued', error=NULL, locked_at=NULL, attempts=0, next_retry_at=NULL, updated_at=NOW()
+        WHERE id=$1 AND status IN ('pending','failed')`, targetID)
+	if err != nil {
+		return fmt.Errorf("queue target: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("queue target: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("target %d cannot be queued", targetID)
+	}
+	return nil
+}
+
+// ClaimTargets atomically moves up to limit queued targets of queued posts to 'publishing', counts the
+// attempt and returns them. Queued targets of scheduled posts are handed to native scheduling. Targets waiting for a retry are skipped until their next_retry_at.
+// Targets stuck in 'publishing' for longer than lease (e.g. the bot died mid-publish) are claimed again.
+// Rows locked by a concurrent claimer are skipped, so several workers can drain the queue safely.
+func (r *repo) ClaimTargets(ctx context.Context, limit int, lease time.Duration) ([]Target, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        UPDATE post_targets SET status='publishing', locked_at=NOW(), attempts=attempts+1, updated_at=NOW()
+        WHERE id IN (
+            SELECT t.id FROM post_targets t
+            JOIN posts p ON p.id = t.post_id
+            WHERE p.status IN ('queued','scheduled')
+              AND ((t.status = 'queued' AND (t.next_retry_at IS NULL OR t.next_retry_at <= NOW()))
+                OR (t.status = 'publishing' AND t.locked_at < NOW() - make_interval(secs => $2)))
+            ORDER BY t.updated_at ASC
+            LIMIT $1
+            FOR UPDATE OF t SKIP LOCKED
+        )
+        RETURNING `+targetColumns, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim targets: %w", err)
+	}
+	return scanTargets(rows)
+}
+
+// ListPostTargets returns all target rows of a post with their publication state.
+func (r *repo) ListPostTargets(ctx context.Context, postID int64) ([]Target, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+targetColumns+` FROM post_targets WHERE post_id=$1 ORDER BY id ASC`, postID)
+	if err != nil {
+		return nil, fmt.Errorf("list post targets: %w", err)
+	}
+	return scanTargets(rows)
+}
+
+// RetryTarget puts a failed target back into the queue, to be claimed again no earlier than at.
+func (r *repo) RetryTarget(ctx context.Context, targetID int64, at time.Time, errText string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE post_targets SET status='queued', error=$2, next_retry_at=$3, locked_at=NULL, updated_at=NOW()
+        WHERE id=$1`, targetID, errText, at)
+	if err != nil {
+		return fmt.Errorf("retry target: %w", err)
+	}
+	return nil
+}
+
+// FinishPost moves a queued post to its final status once none of its targets are queued or publishing.
+// Returns false if the post was already finished (or canceled) or still has work left, so that only one
+// worker reports the outcome.
+func (r *repo) FinishPost(ctx context.Context, postID int64, status string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `UPDATE posts SET status=$2, updated_at=NOW()
+        WHERE id=$1 AND status='queued'
+          AND NOT EXISTS (SELECT 1 FROM post_targets WHERE post_id=$1 AND status IN ('queued','publishing'))`, postID, status)
+	if err != nil {
+		return false, fmt.Errorf("finish post: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("finish post: %w", err)
+	}
+	return n > 0, nil
+}
+
+func scanTargets(rows *sql.Rows) ([]Target, error) {
+	defer rows.Close()
+	var out []Target
+	for rows.Next() {
+		var t Target
+		var account sql.NullInt64
+		var ext, errText sql.NullString
+		var next sql.NullTime
+		if err := rows.Scan(&t.ID, &t.PostID, &t.Platform, &account, &t.Status, &ext, &errText, &t.Attempts, &next); err != nil {
+			return nil, err
+		}
+		if account.Valid {
+			v := account.Int64
+			t.AccountID = &v
+		}
+		if ext.Valid {
+			v := ext.String
+			t.ExternalPostID = &v
+		}
+		if errText.Valid {
+			v := errText.String
+			t.Error = &v
+		}
+		if next.Valid {
+			v := next.Time
+			t.NextRetryAt = &v
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotSchedulable is returned when a post is neither a draft nor already scheduled.
+var ErrNotSchedulable = errors.New("post cannot be scheduled")
+
+// SchedulePost plans a draft (or reschedules a scheduled) post for publication at the given time.
+func (r *repo) SchedulePost(ctx context.Context, postID int64, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE posts SET status='scheduled', scheduled_at=$2, updated_at=NOW()
+        WHERE id=$1 AND status IN ('draft','scheduled')`, postID, at)
+	if err != nil {
+		return fmt.Errorf("schedule post: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("schedule post: %w", err)
+	} else if n == 0 {
+		return ErrNotSchedulable
+	}
+	return nil
+}
+
+// UnschedulePost turns a scheduled post back into a draft. Targets still queued for native
+// scheduling go back to pending.
+func (r *repo) UnschedulePost(ctx context.Context, postID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin unschedule: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `UPDATE posts SET status='draft', scheduled_at=NULL, updated_at=NOW()
+        WHERE id=$1 AND status='scheduled'`, postID)
+	if err != nil {
+		return fmt.Errorf("unschedule post: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("unschedule post: %w", err)
+	} else if n == 0 {
+		return fmt.Errorf("post %d is not scheduled", postID)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE post_targets SET status='pending', locked_at=NULL, next_retry_at=NULL, updated_at=NOW()
+        WHERE post_id=$1 AND status='queued'`, postID); err != nil {
+		return fmt.Errorf("unschedule targets: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit unschedule: %w", err)
+	}
+	return nil
+}
+
+// ListScheduledPosts returns the scheduled posts of a chat, soonest first.
+func (r *repo) ListScheduledPosts(ctx context.Context, chatID int64) ([]Post, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+postColumns+` FROM posts
+        WHERE chat_id=$1 AND status='scheduled' ORDER BY scheduled_at ASC`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("list scheduled posts: %w", err)
+	}
+	defer rows.Close()
+	var out []Post
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *p)
+	}
+	return out, rows.Err()
+}
+
+// ReleaseDuePosts queues every scheduled post whose time has come, together with its targets,
+// in a single statement. Targets scheduled natively are published by their platform at that time
+// and are marked published. Posts locked by a concurrent scheduler are skipped.
+func (r *repo) ReleaseDuePosts(ctx context.Context) ([]Post, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        WITH due AS (
+            UPDATE posts SET status='queued', updated_at=NOW()
+            WHERE id IN (
+                SELECT p.id FROM posts p
+                WHERE p.status='scheduled' AND p.scheduled_at <= NOW()
+                  AND EXISTS (SELECT 1 FROM post_targets t WHERE t.post_id = p.id)
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING `+postColumns+`
+        ), queued AS (
+            UPDATE post_targets SET status='queued', error=NULL, locked_at=NULL, attempts=0, next_retry_at=NULL, updated_at=NOW()
+            WHERE post_id IN (SELECT id FROM due) AND status IN ('pending','queued','failed')
+        ), native AS (
+            UPDATE post_targets SET status='published', updated_at=NOW()
+            WHERE post_id IN (SELECT id FROM due) AND status='scheduled'
+        )
+        SELECT `+postColumns+` FROM due`)
+	if err != nil {
+		return nil, fmt.Errorf("release due posts: %w", err)
+	}
+	defer rows.Close()
+	var out []Post
+	for rows.Next() {
+		p, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *p)
+	}
+	return out, rows.Err()
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -68,3 +69,27 @@ func SplitMessageByLimit(message string, limit int) []string {
 
 	return chunks
 }
+
+// dateTimeLayouts lists the accepted user-facing date/time formats, most specific first
+var dateTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"02.01.2006 15:04",
+}
+
+// ParseDateTime parses a user-supplied date and time. Values without an explicit offset are
+// interpreted in loc.
+func ParseDateTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("unrecognized date/time, use YYYY-MM-DD HH:MM")
+}